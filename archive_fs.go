@@ -0,0 +1,462 @@
+package copy_go
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// archiveSpoolThreshold is the size of the contents of a file kept in memory
+// until it's written into an archive, beyond which they are kept in a temporary file.
+const archiveSpoolThreshold = 8 << 20
+
+// archiveUmask is applied to the permission of the entries created in an archive, like the usual umask.
+const archiveUmask = 0022
+
+// errWrittenEntry is reported when an entry already written into an archive is changed.
+var errWrittenEntry = errors.New("already written into the archive")
+
+// Compressor wraps the writer of an archive to compress it, see Gzip.
+// e.g., zstd of github.com/klauspost/compress can be used by:
+//
+//	func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }
+type Compressor func(w io.Writer) (io.WriteCloser, error)
+
+// archiveFS is the WritableFS shared by TarFS and ZipFS,
+// which keeps the entries until their metadata is set, and writes them by writeEntry.
+//
+// A regular file is written as soon as it's closed, which is after its mode, owner and times are set by Copy.
+// The other entries are written by close, because their metadata is set after their contents.
+type archiveFS struct {
+	mu         sync.Mutex
+	entries    map[string]*archiveEntry
+	pending    []*archiveEntry // in the order of creation
+	closed     bool
+	writeEntry func(e *archiveEntry, contents io.Reader) error // called with mu locked
+}
+
+// archiveEntry is an entry of an archive, which is written or to be written.
+type archiveEntry struct {
+	name     string // rooted by rootedName
+	mode     fs.FileMode
+	uid, gid int
+	atime    time.Time
+	mtime    time.Time
+	size     int64
+	linkname string // target of a symlink, or the name of the original file of a hard link
+	hardlink bool
+	rdev     uint64
+	xattrs   map[string]string
+	written  bool
+	removed  bool
+}
+
+func newArchiveFS(writeEntry func(e *archiveEntry, contents io.Reader) error) archiveFS {
+	return archiveFS{entries: map[string]*archiveEntry{}, writeEntry: writeEntry}
+}
+
+// close writes the entries not written yet.
+// It returns false if it's already closed.
+func (a *archiveFS) close() (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return false, nil
+	}
+	a.closed = true
+
+	for _, e := range a.pending {
+		if e.removed || e.written {
+			continue
+		}
+		if err := a.writeLocked(e, nil); err != nil {
+			return true, err
+		}
+	}
+	a.pending = nil
+	return true, nil
+}
+
+func (a *archiveFS) writeLocked(e *archiveEntry, contents io.Reader) error {
+	if err := a.writeEntry(e, contents); err != nil {
+		return err
+	}
+	e.written = true
+	return nil
+}
+
+// addLocked adds a new entry, which must not exist yet.
+// It's written by close, unless it's a regular file.
+func (a *archiveFS) addLocked(op, name string, mode fs.FileMode) (*archiveEntry, error) {
+	if a.closed {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrClosed}
+	}
+	key := rootedName(name)
+	if _, ok := a.entries[key]; ok || key == "." {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	}
+	now := time.Now()
+	e := &archiveEntry{
+		name:  key,
+		mode:  mode.Type() | mode.Perm()&^archiveUmask,
+		atime: now,
+		mtime: now,
+	}
+	a.entries[key] = e
+	if !mode.IsRegular() {
+		a.pending = append(a.pending, e)
+	}
+	return e, nil
+}
+
+// lookupLocked returns the entry to be changed, which must not be written yet.
+// The root of the archive is not an entry, and nil is returned for it.
+func (a *archiveFS) lookupLocked(op, name string) (*archiveEntry, error) {
+	key := rootedName(name)
+	if key == "." {
+		return nil, nil
+	}
+	e, ok := a.entries[key]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	if e.written {
+		return nil, &fs.PathError{Op: op, Path: name, Err: errWrittenEntry}
+	}
+	return e, nil
+}
+
+func (a *archiveFS) Create(name string) (WritableFile, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if e, ok := a.entries[rootedName(name)]; ok && !e.written && e.mode.IsRegular() && !e.hardlink {
+		return &archiveFile{fs: a, entry: e}, nil // truncated, as nothing has been written
+	}
+	e, err := a.addLocked("create", name, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &archiveFile{fs: a, entry: e}, nil
+}
+
+func (a *archiveFS) Mkdir(name string, perm fs.FileMode) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	_, err := a.addLocked("mkdir", name, fs.ModeDir|perm)
+	return err
+}
+
+func (a *archiveFS) MkdirAll(name string, perm fs.FileMode) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := rootedName(name)
+	if key == "." {
+		return nil
+	}
+	var dir string
+	for _, elem := range strings.Split(key, "/") {
+		dir = path.Join(dir, elem)
+		if e, ok := a.entries[dir]; ok {
+			if !e.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: name, Err: errNotDir}
+			}
+		} else if _, err := a.addLocked("mkdir", dir, fs.ModeDir|perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *archiveFS) Chmod(name string, mode fs.FileMode) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e, err := a.lookupLocked("chmod", name)
+	if e == nil {
+		return err
+	}
+	e.mode = e.mode.Type() | mode&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)
+	return nil
+}
+
+func (a *archiveFS) Chown(name string, uid, gid int) error {
+	return a.Lchown(name, uid, gid) // symlinks are never followed in an archive
+}
+
+func (a *archiveFS) Lchown(name string, uid, gid int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e, err := a.lookupLocked("chown", name)
+	if e == nil {
+		return err
+	}
+	if uid != -1 {
+		e.uid = uid
+	}
+	if gid != -1 {
+		e.gid = gid
+	}
+	return nil
+}
+
+func (a *archiveFS) Chtimes(name string, atime, mtime time.Time) error {
+	return a.Lchtimes(name, atime, mtime) // symlinks are never followed in an archive
+}
+
+func (a *archiveFS) Lchtimes(name string, atime, mtime time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e, err := a.lookupLocked("chtimes", name)
+	if e == nil {
+		return err
+	}
+	if !atime.IsZero() {
+		e.atime = atime
+	}
+	if !mtime.IsZero() {
+		e.mtime = mtime
+	}
+	return nil
+}
+
+func (a *archiveFS) Symlink(oldname, newname string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e, err := a.addLocked("symlink", newname, fs.ModeSymlink|0777)
+	if err != nil {
+		return err
+	}
+	e.mode = fs.ModeSymlink | 0777
+	e.linkname = oldname
+	return nil
+}
+
+// Link writes newname as a hard link to oldname, which must be a regular file already written.
+func (a *archiveFS) Link(oldname, newname string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	orig, ok := a.entries[rootedName(oldname)]
+	if !ok {
+		return &fs.PathError{Op: "link", Path: oldname, Err: fs.ErrNotExist}
+	}
+	if !orig.mode.IsRegular() || !orig.written {
+		return &fs.PathError{Op: "link", Path: oldname, Err: errors.ErrUnsupported}
+	}
+	e, err := a.addLocked("link", newname, orig.mode)
+	if err != nil {
+		return err
+	}
+	*e = *orig
+	e.name, e.linkname, e.hardlink, e.written = rootedName(newname), orig.name, true, false
+	return a.writeLocked(e, nil)
+}
+
+// mknod adds a named pipe or a device.
+func (a *archiveFS) mknod(op, name string, mode fs.FileMode, dev uint64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e, err := a.addLocked(op, name, mode)
+	if err != nil {
+		return err
+	}
+	e.rdev = dev
+	return nil
+}
+
+func (a *archiveFS) Lsetxattr(name, attr string, value []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e, err := a.lookupLocked("lsetxattr", name)
+	if e == nil {
+		return err
+	}
+	if e.xattrs == nil {
+		e.xattrs = map[string]string{}
+	}
+	e.xattrs[attr] = string(value)
+	return nil
+}
+
+// Remove removes an entry not written yet.
+func (a *archiveFS) Remove(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	e, err := a.lookupLocked("remove", name)
+	if e == nil {
+		if err == nil {
+			err = &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+		}
+		return err
+	}
+	if e.mode.IsDir() {
+		for k := range a.entries {
+			if strings.HasPrefix(k, e.name+"/") {
+				return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+			}
+		}
+	}
+	e.removed = true
+	delete(a.entries, e.name)
+	return nil
+}
+
+// RemoveAll removes an entry and its children, none of which must be written yet.
+func (a *archiveFS) RemoveAll(name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := rootedName(name)
+	var removing []string
+	for k, e := range a.entries {
+		if key == "." || k == key || strings.HasPrefix(k, key+"/") {
+			if e.written {
+				return &fs.PathError{Op: "removeall", Path: name, Err: errWrittenEntry}
+			}
+			removing = append(removing, k)
+		}
+	}
+	for _, k := range removing {
+		a.entries[k].removed = true
+		delete(a.entries, k)
+	}
+	return nil
+}
+
+// Rename renames an entry and its children, none of which must be written yet.
+func (a *archiveFS) Rename(oldpath, newpath string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	oldkey, newkey := rootedName(oldpath), rootedName(newpath)
+	if e, err := a.lookupLocked("rename", oldpath); e == nil {
+		if err == nil {
+			err = &fs.PathError{Op: "rename", Path: oldpath, Err: fs.ErrInvalid}
+		}
+		return err
+	}
+	if _, ok := a.entries[newkey]; ok || newkey == "." {
+		return &fs.PathError{Op: "rename", Path: newpath, Err: fs.ErrExist}
+	}
+	if strings.HasPrefix(newkey, oldkey+"/") {
+		return &fs.PathError{Op: "rename", Path: newpath, Err: fs.ErrInvalid}
+	}
+
+	moved := map[string]*archiveEntry{}
+	for k, e := range a.entries {
+		if k == oldkey || strings.HasPrefix(k, oldkey+"/") {
+			if e.written {
+				return &fs.PathError{Op: "rename", Path: oldpath, Err: errWrittenEntry}
+			}
+			moved[newkey+strings.TrimPrefix(k, oldkey)] = e
+		}
+	}
+	for k, e := range moved {
+		delete(a.entries, e.name)
+		e.name = k
+		a.entries[k] = e
+	}
+	return nil
+}
+
+func (a *archiveFS) Lstat(name string) (fs.FileInfo, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := rootedName(name)
+	if key == "." {
+		return &archiveInfo{archiveEntry{name: ".", mode: fs.ModeDir | 0755}}, nil
+	}
+	e, ok := a.entries[key]
+	if !ok {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrNotExist}
+	}
+	return &archiveInfo{*e}, nil
+}
+
+// archiveInfo is a snapshot of an archiveEntry as fs.FileInfo.
+type archiveInfo struct {
+	entry archiveEntry
+}
+
+func (i *archiveInfo) Name() string       { return path.Base(i.entry.name) }
+func (i *archiveInfo) Size() int64        { return i.entry.size }
+func (i *archiveInfo) Mode() fs.FileMode  { return i.entry.mode }
+func (i *archiveInfo) ModTime() time.Time { return i.entry.mtime }
+func (i *archiveInfo) IsDir() bool        { return i.entry.mode.IsDir() }
+func (i *archiveInfo) Sys() any           { return nil }
+
+// archiveFile is a WritableFile of an archive, which keeps the contents until it's closed.
+type archiveFile struct {
+	fs     *archiveFS
+	entry  *archiveEntry
+	buffer bytes.Buffer
+	spool  *os.File // used instead of buffer once the contents get large
+	size   int64
+}
+
+func (f *archiveFile) Write(p []byte) (int, error) {
+	if f.spool == nil && f.buffer.Len()+len(p) > archiveSpoolThreshold {
+		spool, err := os.CreateTemp("", "copy-go-archive-*")
+		if err != nil {
+			return 0, err
+		}
+		f.spool = spool
+		if _, err := f.buffer.WriteTo(spool); err != nil {
+			return 0, err
+		}
+	}
+	var n int
+	var err error
+	if f.spool != nil {
+		n, err = f.spool.Write(p)
+	} else {
+		n, err = f.buffer.Write(p)
+	}
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *archiveFile) Sync() error {
+	return nil
+}
+
+// Close writes the file into the archive.
+func (f *archiveFile) Close() (err error) {
+	var contents io.Reader = &f.buffer
+	if f.spool != nil {
+		defer os.Remove(f.spool.Name())
+		defer fclose(f.spool, &err)
+		if _, err = f.spool.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		contents = f.spool
+	}
+
+	a := f.fs
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if f.entry.written || f.entry.removed {
+		return nil
+	}
+	if a.closed {
+		return &fs.PathError{Op: "close", Path: f.entry.name, Err: fs.ErrClosed}
+	}
+	f.entry.size = f.size
+	return a.writeLocked(f.entry, contents)
+}
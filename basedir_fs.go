@@ -0,0 +1,95 @@
+package copy_go
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BaseDirFS is a WritableFS rooted at a directory.
+// Names are resolved relative to the directory, and nothing can be written outside of it,
+// neither by ".." nor by symlinks, see os.Root.
+type BaseDirFS struct {
+	root *os.Root
+}
+
+var _ LchownFS = (*BaseDirFS)(nil)
+
+// NewBaseDirFS opens dir as the root of a BaseDirFS.
+// The BaseDirFS must be closed by Close after use.
+func NewBaseDirFS(dir string) (*BaseDirFS, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &BaseDirFS{root: root}, nil
+}
+
+// Close closes the root directory.
+func (b *BaseDirFS) Close() error {
+	return b.root.Close()
+}
+
+// name makes name relative to the root, so that both "/a/b" and "a/b" mean the same file.
+func (b *BaseDirFS) name(name string) string {
+	name = filepath.Clean(string(filepath.Separator) + name)
+	if rel, err := filepath.Rel(string(filepath.Separator), name); err == nil {
+		return rel
+	}
+	return name
+}
+
+func (b *BaseDirFS) Create(name string) (WritableFile, error) {
+	return b.root.Create(b.name(name))
+}
+
+func (b *BaseDirFS) Mkdir(name string, perm fs.FileMode) error {
+	return b.root.Mkdir(b.name(name), perm)
+}
+
+func (b *BaseDirFS) MkdirAll(name string, perm fs.FileMode) error {
+	return b.root.MkdirAll(b.name(name), perm)
+}
+
+func (b *BaseDirFS) Chmod(name string, mode fs.FileMode) error {
+	return b.root.Chmod(b.name(name), mode)
+}
+
+func (b *BaseDirFS) Chown(name string, uid, gid int) error {
+	return b.root.Chown(b.name(name), uid, gid)
+}
+
+func (b *BaseDirFS) Lchown(name string, uid, gid int) error {
+	return b.root.Lchown(b.name(name), uid, gid)
+}
+
+func (b *BaseDirFS) Chtimes(name string, atime, mtime time.Time) error {
+	return b.root.Chtimes(b.name(name), atime, mtime)
+}
+
+// Symlink creates newname as a symlink to oldname, which is written as it is.
+// The symlink can point anywhere, but it is never followed by the BaseDirFS outside of the root.
+func (b *BaseDirFS) Symlink(oldname, newname string) error {
+	return b.root.Symlink(oldname, b.name(newname))
+}
+
+func (b *BaseDirFS) Link(oldname, newname string) error {
+	return b.root.Link(b.name(oldname), b.name(newname))
+}
+
+func (b *BaseDirFS) Remove(name string) error {
+	return b.root.Remove(b.name(name))
+}
+
+func (b *BaseDirFS) RemoveAll(name string) error {
+	return b.root.RemoveAll(b.name(name))
+}
+
+func (b *BaseDirFS) Rename(oldpath, newpath string) error {
+	return b.root.Rename(b.name(oldpath), b.name(newpath))
+}
+
+func (b *BaseDirFS) Lstat(name string) (fs.FileInfo, error) {
+	return b.root.Lstat(b.name(name))
+}
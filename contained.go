@@ -0,0 +1,68 @@
+package copy_go
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// assureContained checks, in Contained mode, that creating dst doesn't write outside of the dst root.
+// This is the check RESOLVE_BENEATH and RESOLVE_NO_SYMLINKS of openat2(2) would make:
+// dst must be under the dst root, and none of the path components below the root,
+// including dst itself, may be an existing symlink.
+// Components not existing yet are fine, because they are going to be created as real directories.
+// It's made by Lstat before writing, so a component replaced with a symlink in between by another process
+// is not detected, which only a DestFS resolving the names beneath its root, e.g. BaseDirFS, stands against.
+func assureContained(dst string, opt Options) error {
+	rel, ok := relativeInTree(opt.intent.dst, dst)
+	if !ok {
+		return &UnsafePathError{Path: dst, Reason: "outside of " + opt.intent.dst}
+	}
+	if rel == "." {
+		return nil // the root itself is given by caller, trust it
+	}
+
+	name := opt.intent.dst
+	for _, elem := range splitPath(rel) {
+		name = filepath.Join(name, elem)
+		info, err := opt.DestFS.Lstat(name)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return &UnsafePathError{Path: dst, Reason: name + " is a symlink"}
+		}
+	}
+	return nil
+}
+
+// assureContainedSymlink checks, in Contained mode, that orig, the target of a symlink to be copied by Deep,
+// is inside of the src root after resolving all the symlinks on the way.
+// The last path component is not resolved here, because, if it's a symlink,
+// it's going to be checked on its own turn.
+func assureContainedSymlink(src, orig string, opt Options) error {
+	dir, err := filepath.EvalSymlinks(filepath.Dir(orig))
+	if err != nil {
+		return err
+	}
+	if _, ok := relativeInTree(opt.intent.srcReal, filepath.Join(dir, filepath.Base(orig))); !ok {
+		return &SymlinkOutOfTreeError{Src: src, Target: orig}
+	}
+	return nil
+}
+
+func splitPath(rel string) []string {
+	var elems []string
+	for rel != "." && rel != string(filepath.Separator) && rel != "" {
+		elems = append(elems, filepath.Base(rel))
+		rel = filepath.Dir(rel)
+	}
+	for i, j := 0, len(elems)-1; i < j; i, j = i+1, j-1 {
+		elems[i], elems[j] = elems[j], elems[i]
+	}
+	return elems
+}
@@ -0,0 +1,132 @@
+//go:build !windows && !plan9 && !js
+
+package copy_go
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopy_containedSymlinks(t *testing.T) {
+	root := t.TempDir()
+	src := filepath.Join(root, "src")
+	if err := os.MkdirAll(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "dir", "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name    string
+		Link    string
+		Target  string
+		OK      bool
+		Symlink SymlinkAction
+	}{
+		{"deep in tree", "in", "dir/file", true, Deep},
+		{"deep escaping", "out", "../secret", false, Deep},
+		{"deep escaping via symlinked dir", "via/secret", "", false, Deep},
+		{"shallow in tree", "in", "dir/file", true, Shallow},
+		{"shallow escaping", "out", "../../secret", false, Shallow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			_ = os.RemoveAll(filepath.Join(src, "link"))
+			if err := os.MkdirAll(filepath.Join(src, "link"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if tt.Target == "" {
+				// link/via -> .. (root, outside of src), link/escape -> via/secret
+				if err := os.Symlink(root, filepath.Join(src, "link", "via")); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Symlink(filepath.Join("via", "secret"), filepath.Join(src, "link", "escape")); err != nil {
+					t.Fatal(err)
+				}
+			} else if err := os.Symlink(filepath.Join("..", tt.Target), filepath.Join(src, "link", tt.Link)); err != nil {
+				t.Fatal(err)
+			}
+
+			err := Copy(src, filepath.Join(t.TempDir(), "dst"), Options{
+				Contained: true,
+				OnSymlink: func(src string) SymlinkAction {
+					if filepath.Base(src) == "via" {
+						return Skip
+					}
+					return tt.Symlink
+				},
+			})
+			var outOfTree *SymlinkOutOfTreeError
+			if tt.OK && err != nil {
+				t.Errorf("Copy() = %v", err)
+			} else if !tt.OK && !errors.As(err, &outOfTree) {
+				t.Errorf("Copy() = %v, want *SymlinkOutOfTreeError", err)
+			}
+		})
+	}
+}
+
+func TestCopy_containedDestination(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "dir", "passwd"), []byte("pwned"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	outside := t.TempDir()
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dst, "dir")); err != nil {
+		t.Fatal(err)
+	}
+
+	var unsafe *UnsafePathError
+	if err := Copy(src, dst, Options{Contained: true}); !errors.As(err, &unsafe) {
+		t.Errorf("Copy() = %v, want *UnsafePathError", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "passwd")); !os.IsNotExist(err) {
+		t.Errorf("written through symlink: %v", err)
+	}
+
+	err := Copy(src, dst, Options{
+		Contained: true,
+		RenameDestination: func(src, dst string) (string, error) {
+			return filepath.Join(outside, filepath.Base(dst)), nil
+		},
+	})
+	if !errors.As(err, &unsafe) {
+		t.Errorf("Copy() = %v, want *UnsafePathError", err)
+	}
+}
+
+func TestCopy_containedSymlinkThroughSymlink(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(filepath.Join(src, "d1", "d2"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// s -> the src root, in the tree, but s/../.. is above it, not d1 as filepath.Join collapses it to
+	if err := os.Symlink("../..", filepath.Join(src, "d1", "d2", "s")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Copy(src, filepath.Join(t.TempDir(), "dst"), Options{Contained: true}); err != nil {
+		t.Fatalf("Copy() = %v", err)
+	}
+
+	if err := os.Symlink("s/../..", filepath.Join(src, "d1", "d2", "a")); err != nil {
+		t.Fatal(err)
+	}
+	var outOfTree *SymlinkOutOfTreeError
+	if err := Copy(src, filepath.Join(t.TempDir(), "dst"), Options{Contained: true}); !errors.As(err, &outOfTree) {
+		t.Errorf("Copy() = %v, want *SymlinkOutOfTreeError", err)
+	}
+}
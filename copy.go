@@ -2,59 +2,99 @@ package copy_go
 
 import (
 	"bufio"
-	"context"
+	"errors"
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
-
-	"golang.org/x/sync/errgroup"
-	"golang.org/x/sync/semaphore"
 )
 
 // Copy copies src to dst, no matter if src is a file or a directory
-func Copy(src, dst string, opts ...Options) (err error) {
-	src = assureHomeDir(src)
-	dst = assureHomeDir(dst)
+func Copy(src, dst string, opts ...Options) error {
+	_, err := CopyWithResult(src, dst, opts...)
+	return err
+}
+
+// CopyWithResult copies src to dst as Copy does, and reports what has been copied
+func CopyWithResult(src, dst string, opts ...Options) (Result, error) {
+	if len(opts) == 0 || opts[0].FS == nil {
+		src = assureHomeDir(src) // paths of fs.FS are always relative to its root
+	}
+	if len(opts) == 0 || isOSFS(opts[0].DestFS) {
+		dst = assureHomeDir(dst)
+	} else {
+		dst = filepath.Clean(dst)
+	}
 
 	opt := assureOptions(src, dst, opts...)
+	opt.intent.report = &report{}
+	if opt.PreserveHardlinks {
+		opt.intent.hardlinks = newHardlinks()
+	}
 
+	err := copyRoot(src, dst, opt)
+	return opt.intent.report.snapshot(), err
+}
+
+// copyRoot is the entry point of the copy of the whole tree.
+func copyRoot(src, dst string, opt Options) (err error) {
 	if opt.NumOfWorkers > 1 {
-		opt.intent.ctx = context.Background()
-		opt.intent.sem = semaphore.NewWeighted(opt.NumOfWorkers)
+		opt.intent.pool = newWorkerPool(opt.NumOfWorkers)
+		defer opt.intent.pool.close()
 	}
 
-	var info fs.FileInfo
-	if opt.FS != nil {
-		info, err = fs.Stat(opt.FS, src)
-	} else {
-		info, err = os.Lstat(src)
-	}
+	info, err := lstat(src, opt)
 	if err != nil {
 		return onError(src, dst, err, opt)
 	}
 
-	return switchboard(src, dst, info, opt)
+	opt.intent.ancestors = rootAncestors(src, opt)
+	if opt.OneFileSystem {
+		opt.intent.rootDev, _, _, opt.intent.oneFS = getInode(info)
+	}
+	if opt.Contained && opt.FS == nil {
+		if opt.intent.srcReal, err = filepath.EvalSymlinks(src); err != nil {
+			return onError(src, dst, err, opt)
+		}
+	}
+
+	if opt.CheckFreeSpace {
+		if err = preflight(src, dst, info, opt); err != nil {
+			return err
+		}
+	}
+
+	opt.intent.syncer = newSyncer(dst, opt)
+	if err = switchboard(src, dst, info, opt); err != nil {
+		return err
+	}
+	if err = opt.intent.hardlinks.finish(opt); err != nil {
+		return err
+	}
+	return opt.intent.syncer.finish(dst)
 }
 
 // switchboard switches proper copy functions regarding file type, etc...
 // If there would be anything else here, add a case to this switchboard.
 func switchboard(src, dst string, info os.FileInfo, opt Options) (err error) {
-	if info.Mode()&os.ModeDevice != 0 && !opt.Specials {
+	if dst, err = renameDestination(src, dst, info, opt); err != nil {
 		return onError(src, dst, err, opt)
 	}
 
-	if opt.RenameDestination != nil {
-		if dst, err = opt.RenameDestination(src, dst); err != nil {
+	if opt.Contained {
+		if err = assureContained(dst, opt); err != nil {
 			return onError(src, dst, err, opt)
 		}
 	}
 
 	switch {
 	case info.Mode()&os.ModeSymlink != 0:
-		err = onSymlink(src, dst, opt)
+		err = onSymlink(src, dst, info, opt)
 	case info.Mode()&os.ModeNamedPipe != 0:
-		err = pcopy(dst, info)
+		err = pcopy(src, dst, info, opt)
+	case info.Mode()&(os.ModeDevice|os.ModeSocket) != 0:
+		err = onSpecial(src, dst, info, opt)
 	case info.IsDir():
 		err = dcopy(src, dst, info, opt)
 	default:
@@ -67,6 +107,16 @@ func switchboard(src, dst string, info os.FileInfo, opt Options) (err error) {
 // fcopy is for just a file,
 // with considering existence of parent directory and file permission.
 func fcopy(src, dst string, info os.FileInfo, opt Options) (err error) {
+	var link *hardlink
+	if isHardlinked(info, opt) {
+		var first bool
+		link, first = opt.intent.hardlinks.claim(info, dst)
+		if !first {
+			return hcopy(src, dst, info, link, opt)
+		}
+		defer link.finish(&err)
+	}
+
 	var readCloser io.ReadCloser
 	if opt.FS != nil {
 		readCloser, err = opt.FS.Open(src)
@@ -81,74 +131,139 @@ func fcopy(src, dst string, info os.FileInfo, opt Options) (err error) {
 	}
 	defer fclose(readCloser, &err)
 
-	if err = os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+	if err = opt.DestFS.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
 		return err
 	}
 
-	f, err := os.Create(dst)
+	f, err := opt.DestFS.Create(dst)
 	if err != nil {
 		return err
 	}
 	defer fclose(f, &err)
 
-	chmodfunc, err := opt.PermissionControl(info, dst)
+	if osfile, ok := f.(*os.File); ok && opt.Preallocate {
+		if err = preallocate(osfile, info.Size()); err != nil {
+			return err
+		}
+	}
+
+	guard := newModeGuard(opt.DestFS, dst)
+	chmodfunc, err := controlPermission(info, dst, guard, opt)
 	if err != nil {
 		return err
 	}
 	chmodfunc(&err)
 
-	var reader = bufio.NewReader(readCloser)
-	var writer = bufio.NewWriter(f)
-	if opt.CopyBufferSize > 0 {
-		reader = bufio.NewReaderSize(reader, opt.CopyBufferSize)
-		writer = bufio.NewWriterSize(writer, opt.CopyBufferSize)
-	}
+	readerAt, isReaderAt := readCloser.(io.ReaderAt)
+	chunkWriter, isChunkWriter := f.(chunkWriter)
+	if isReaderAt && isChunkWriter && shouldCopyFileInChunks(info, opt) {
+		if err = fcopyChunks(readerAt, chunkWriter, info.Size(), opt); err != nil {
+			return err
+		}
+	} else {
+		var reader = bufio.NewReader(readCloser)
+		var writer = bufio.NewWriter(f)
+		if opt.CopyBufferSize > 0 {
+			reader = bufio.NewReaderSize(reader, opt.CopyBufferSize)
+			writer = bufio.NewWriterSize(writer, opt.CopyBufferSize)
+		}
 
-	if _, err = io.Copy(writer, reader); err != nil {
-		return err
-	}
+		if _, err = io.Copy(writer, reader); err != nil {
+			return err
+		}
 
-	if err = writer.Flush(); err != nil {
-		return err
+		if err = writer.Flush(); err != nil {
+			return err
+		}
 	}
 
-	if opt.Sync {
+	if opt.Sync || opt.intent.syncer.syncFile() {
 		err = f.Sync()
 	}
 
-	if opt.PreserveOwner {
-		if err := preserveOwner(src, dst, info); err != nil {
+	if opt.PreserveXattrs {
+		if err := preserveXattrs(src, dst, info, opt); err != nil {
 			return err
 		}
 	}
 
+	ownerSet := false
+	if shouldPreserveOwner(opt) {
+		set, err := preserveOwner(dst, info, opt)
+		if err != nil {
+			return err
+		}
+		ownerSet = set
+	}
+	guard.release(src, ownerSet, opt, &err)
+
 	if opt.PreserveTimes {
-		if err := preserveTimes(dst, info); err != nil {
+		if err := preserveTimes(dst, info, opt.DestFS); err != nil {
 			return err
 		}
 	}
 
+	if opt.PreserveFlags && err == nil {
+		err = preserveFlags(src, dst, info, opt)
+	}
+
+	if err == nil {
+		if link != nil {
+			link.created = true
+		}
+		opt.intent.report.created(info.Mode())
+	}
 	return err
 }
 
 // dcopy is for a directory,
 // with scanning contents inside the directory and pass everything to "copy" recursively.
 func dcopy(srcdir, dstdir string, info os.FileInfo, opt Options) (err error) {
-	if skip, err := onDirExists(srcdir, dstdir, opt); err != nil {
+	copyDir, walkInto := shouldWalkInto(info, opt)
+	if !copyDir {
+		return nil
+	}
+
+	if skip, err := onDirExists(srcdir, dstdir, info, opt); err != nil {
 		return err
 	} else if skip {
 		return nil
 	}
 
+	opt.intent.ancestors = opt.intent.ancestors.push(srcdir, info)
+
+	if opt.intent.syncer != nil {
+		// after everything below, including the deferred ones
+		defer func() {
+			if err == nil {
+				err = opt.intent.syncer.dir(dstdir)
+			}
+		}()
+	}
+
+	if opt.PreserveFlags {
+		// after chmodfunc and guard below, so that immutable is set last
+		defer func() {
+			if err == nil {
+				err = preserveFlags(srcdir, dstdir, info, opt)
+			}
+		}()
+	}
+
 	// make dst dir with perm 0755 so that everything writable
-	chmodfunc, err := opt.PermissionControl(info, dstdir)
+	guard := newModeGuard(opt.DestFS, dstdir)
+	chmodfunc, err := controlPermission(info, dstdir, guard, opt)
 	if err != nil {
 		return err
 	}
+	ownerSet := false
+	defer func() { guard.release(srcdir, ownerSet, opt, &err) }()
 	defer chmodfunc(&err)
 
 	var entries []fs.DirEntry
-	if opt.FS != nil {
+	if !walkInto {
+		// the directory is left empty, as PermissionControl has made it
+	} else if opt.FS != nil {
 		entries, err = fs.ReadDir(opt.FS, srcdir)
 		if err != nil {
 			return err
@@ -172,7 +287,7 @@ func dcopy(srcdir, dstdir string, info os.FileInfo, opt Options) (err error) {
 		contents = append(contents, info)
 	}
 
-	if yes, err := shouldCopyDirectoryConcurrent(srcdir, dstdir, opt); err != nil {
+	if yes, err := shouldCopyDirectoryConcurrent(srcdir, dstdir, info, opt); err != nil {
 		return err
 	} else if yes {
 		if err := dcopyConcurrent(srcdir, dstdir, contents, opt); err != nil {
@@ -184,34 +299,43 @@ func dcopy(srcdir, dstdir string, info os.FileInfo, opt Options) (err error) {
 		}
 	}
 
-	if opt.PreserveOwner {
-		if err := preserveOwner(srcdir, dstdir, info); err != nil {
+	if opt.PreserveXattrs {
+		if err := preserveXattrs(srcdir, dstdir, info, opt); err != nil {
+			return err
+		}
+	}
+
+	if shouldPreserveOwner(opt) {
+		if ownerSet, err = preserveOwner(dstdir, info, opt); err != nil {
 			return err
 		}
 	}
 
+	// every child is finished here, even the ones copied by the other workers,
+	// so that nothing changes the modification time of dstdir after this
 	if opt.PreserveTimes {
-		if err := preserveTimes(dstdir, info); err != nil {
+		if err := preserveTimes(dstdir, info, opt.DestFS); err != nil {
 			return err
 		}
 	}
 
+	opt.intent.report.created(info.Mode())
 	return
 }
 
-func onDirExists(srcdir string, dstdir string, opt Options) (bool, error) {
-	_, err := os.Stat(dstdir)
-	if err == nil && opt.OnDirExists != nil && dstdir != opt.intent.dst {
-		switch opt.OnDirExists(srcdir, dstdir) {
+func onDirExists(srcdir string, dstdir string, info os.FileInfo, opt Options) (bool, error) {
+	_, err := opt.DestFS.Lstat(dstdir)
+	if err == nil && dstdir != opt.intent.dst {
+		switch dirExistsAction(srcdir, dstdir, info, opt) {
 		case Replace:
-			if err := os.RemoveAll(dstdir); err != nil {
+			if err := opt.DestFS.RemoveAll(dstdir); err != nil {
 				return false, err
 			}
 		case Untouchable:
 			return true, nil
 		case Merge: // case "Merge" is default behaviour. Go through.
 		}
-	} else if err != nil && !os.IsNotExist(err) {
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return true, err // Unwelcome error type...
 	}
 	return false, nil
@@ -221,60 +345,55 @@ func dcopySequential(srcdir string, dstdir string, contents []fs.FileInfo, opt O
 	for _, content := range contents {
 		cs := filepath.Join(srcdir, content.Name())
 		cd := filepath.Join(dstdir, content.Name())
-		if err := copyNextOrSkip(cs, cd, content, opt); err != nil {
+		if err := copyNextOrSkip(cs, cd, content, opt.child(content.Name())); err != nil {
 			return err // exit immediately if any error
 		}
 	}
 	return nil
 }
 
+// dcopyConcurrent queues every content of the directory into the worker pool,
+// and helps the workers until all of them are copied.
 func dcopyConcurrent(srcdir string, dstdir string, contents []fs.FileInfo, opt Options) error {
-	group, ctx := errgroup.WithContext(opt.intent.ctx)
-	getRoutine := func(cs, cd string, content os.FileInfo) func() error {
-		return func() error {
-			if content.IsDir() {
-				return copyNextOrSkip(cs, cd, content, opt)
-			}
-			if err := opt.intent.sem.Acquire(ctx, 1); err != nil {
-				return err
-			}
-			err := copyNextOrSkip(cs, cd, content, opt)
-			opt.intent.sem.Release(1)
-			return err
-		}
-	}
+	group := opt.intent.pool.group()
 	for _, content := range contents {
-		csd := filepath.Join(srcdir, content.Name())
-		cdd := filepath.Join(dstdir, content.Name())
-		group.Go(getRoutine(csd, cdd, content))
+		cs := filepath.Join(srcdir, content.Name())
+		cd := filepath.Join(dstdir, content.Name())
+		copt := opt.child(content.Name())
+		group.Go(func() error {
+			return copyNextOrSkip(cs, cd, content, copt)
+		})
 	}
 	return group.Wait()
 }
 
-func onSymlink(src, dst string, opt Options) error {
+func onSymlink(src, dst string, info os.FileInfo, opt Options) error {
+	if opt.FS != nil {
+		if _, ok := opt.FS.(fs.ReadLinkFS); !ok {
+			opt.intent.report.skipped(src)
+			return nil // symlinks are not supported by the FS
+		}
+	}
+
 	switch opt.OnSymlink(src) {
 	case Deep:
-		orig, err := os.Readlink(src)
+		orig, originfo, err := resolveSymlink(src, opt)
 		if err != nil {
 			return err
 		}
-		if !filepath.IsAbs(orig) {
-			orig = filepath.Join(filepath.Dir(src), orig) // orig is a relative link, need to concat src dir
+		opt.intent.ancestors = opt.intent.ancestors.push(src, info)
+		if opt.intent.ancestors.contains(orig, originfo) {
+			return onSymlinkLoop(src, dst, orig, info, opt)
 		}
-		info, err := os.Lstat(orig)
-		if err != nil {
-			return err
+		if opt.Contained && opt.FS == nil {
+			if err := assureContainedSymlink(src, orig, opt); err != nil {
+				return err
+			}
 		}
-		return copyNextOrSkip(orig, dst, info, opt)
+		return copyNextOrSkip(orig, dst, originfo, opt)
 
 	case Shallow:
-		if err := lcopy(src, dst); err != nil {
-			return err
-		}
-		if opt.PreserveTimes {
-			return preserveLtimes(src, dst)
-		}
-		return nil
+		return onShallowSymlink(src, dst, info, opt)
 
 	case Skip:
 		fallthrough
@@ -284,41 +403,179 @@ func onSymlink(src, dst string, opt Options) error {
 	}
 }
 
+// resolveSymlink reads the target of a symlink to be copied by Deep.
+func resolveSymlink(src string, opt Options) (string, os.FileInfo, error) {
+	orig, err := readlink(src, opt)
+	if err != nil {
+		return "", nil, err
+	}
+	if opt.FS != nil {
+		if path.IsAbs(orig) {
+			return "", nil, &SymlinkOutOfTreeError{Src: src, Target: orig}
+		}
+		if orig = path.Join(path.Dir(src), orig); !fs.ValidPath(orig) {
+			return "", nil, &SymlinkOutOfTreeError{Src: src, Target: orig}
+		}
+	} else if !filepath.IsAbs(orig) {
+		orig = filepath.Join(filepath.Dir(src), orig) // orig is a relative link, need to concat src dir
+	}
+	info, err := lstat(orig, opt)
+	if err != nil {
+		return "", nil, err
+	}
+	return orig, info, nil
+}
+
+// readlink returns the target of the symlink src, from Options.FS if given.
+func readlink(src string, opt Options) (string, error) {
+	if opt.FS != nil {
+		return fs.ReadLink(opt.FS, src)
+	}
+	return os.Readlink(src)
+}
+
+func onShallowSymlink(src, dst string, info os.FileInfo, opt Options) error {
+	if err := lcopy(src, dst, opt); err != nil {
+		return err
+	}
+	opt.intent.report.created(os.ModeSymlink)
+	if shouldPreserveOwner(opt) {
+		if err := preserveLowner(dst, info, opt); err != nil {
+			return err
+		}
+	}
+	if opt.PreserveTimes {
+		return preserveLtimes(dst, info, opt.DestFS)
+	}
+	return nil
+}
+
+// onSymlinkLoop lets caller decide what to do with a symlink which would make Deep go around in circles
+func onSymlinkLoop(src, dst, orig string, info os.FileInfo, opt Options) error {
+	switch opt.OnSymlinkLoop(src) {
+	case LoopShallow:
+		return onShallowSymlink(src, dst, info, opt)
+	case LoopSkip:
+		return nil
+	default:
+		return &SymlinkLoopError{Src: src, Target: orig}
+	}
+}
+
+// onSpecial creates a device or a socket if it's allowed by Specials and OnSocket,
+// otherwise records it as skipped.
+func onSpecial(src, dst string, info os.FileInfo, opt Options) error {
+	if !shouldCopySpecial(src, info, opt) {
+		opt.intent.report.skipped(src)
+		return nil
+	}
+	return scopy(src, dst, info, opt)
+}
+
 // copyNextOrSkip decides if this src should be copied or not.
 // because this "copy" could be called recursively,
 // "info" MUST be given here, NOT nil.
 func copyNextOrSkip(src, dst string, info os.FileInfo, opt Options) error {
-	if opt.Skip != nil {
-		skip, err := opt.Skip(src, dst, info)
-		if err != nil {
-			return err
-		}
-		if skip {
-			return nil
-		}
+	if skip, err := shouldSkip(src, dst, info, opt); err != nil {
+		return err
+	} else if skip {
+		return nil
 	}
 	return switchboard(src, dst, info, opt)
 }
 
 // lcopy is for a symlink, with just creating a new symlink by replicating src symlink
-func lcopy(src, dst string) error {
-	orig, err := os.Readlink(src)
+func lcopy(src, dst string, opt Options) error {
+	orig, err := readlink(src, opt)
 	// ** might be controlled by Options in the future **
 	if err != nil {
 		if os.IsNotExist(err) {
-			return os.Symlink(src, dst) // copy symlink even if not existing
+			return opt.DestFS.Symlink(src, dst) // copy symlink even if not existing
 		}
 		return err
 	}
 
 	// ** might be controlled by SymlinkExistsAction **
-	if _, err = os.Lstat(dst); err == nil {
-		if err = os.Remove(dst); err != nil {
+	if exists(opt.DestFS, dst) {
+		if err = opt.DestFS.Remove(dst); err != nil {
+			return err
+		}
+	}
+
+	target, err := symlinkTarget(src, dst, orig, opt)
+	if err != nil {
+		return err
+	}
+
+	return opt.DestFS.Symlink(target, dst)
+}
+
+// pcopy is for a named pipe, with creating a new one by mkfifo(3)
+func pcopy(src, dst string, info os.FileInfo, opt Options) error {
+	mknodfs, ok := opt.DestFS.(MknodFS)
+	if !ok {
+		opt.intent.report.skipped(src)
+		return nil
+	}
+	if err := opt.DestFS.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	if err := mknodfs.Mkfifo(dst, info.Mode().Perm()); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			opt.intent.report.skipped(src)
+			return nil
+		}
+		return err
+	}
+	opt.intent.report.created(info.Mode())
+	return preserveSpecial(dst, info, opt)
+}
+
+// scopy is for a device or a socket,
+// with creating a new node of the same type and the same device number by mknod(2).
+func scopy(src, dst string, info os.FileInfo, opt Options) error {
+	mknodfs, ok := opt.DestFS.(MknodFS)
+	if !ok {
+		opt.intent.report.skipped(src)
+		return nil
+	}
+	rdev, ok := getRdev(info)
+	if !ok && info.Mode()&os.ModeDevice != 0 {
+		opt.intent.report.skipped(src) // the device number is unknown
+		return nil
+	}
+
+	if err := opt.DestFS.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	if exists(opt.DestFS, dst) {
+		if err := opt.DestFS.Remove(dst); err != nil {
 			return err
 		}
 	}
 
-	return os.Symlink(orig, dst)
+	if err := mknodfs.Mknod(dst, info.Mode(), rdev); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			opt.intent.report.skipped(src)
+			return nil
+		}
+		return err
+	}
+	opt.intent.report.created(info.Mode())
+	return preserveSpecial(dst, info, opt)
+}
+
+// preserveSpecial preserves the owner and the times of a named pipe, a device or a socket.
+func preserveSpecial(dst string, info os.FileInfo, opt Options) error {
+	if shouldPreserveOwner(opt) {
+		if _, err := preserveOwner(dst, info, opt); err != nil {
+			return err
+		}
+	}
+	if opt.PreserveTimes {
+		return preserveTimes(dst, info, opt.DestFS)
+	}
+	return nil
 }
 
 // fclose ANYHOW closes file,
@@ -0,0 +1,164 @@
+package copy_go
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	defaultChunkSize       = 64 << 20
+	defaultChunkBufferSize = 1 << 20
+)
+
+// workerPool runs copy jobs on a fixed number of goroutines.
+//
+// Jobs never block a worker while waiting for other jobs:
+// a goroutine waiting on a jobGroup runs queued jobs itself until the group is done.
+// That's why directories can be listed and copied by the same workers as files
+// without deadlocking, no matter how deep the tree is.
+type workerPool struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	jobs   []func() // LIFO, so that a waiting goroutine prefers the jobs it just queued
+	closed bool
+}
+
+// newWorkerPool starts a pool of n-1 goroutines,
+// because the goroutine calling Copy works as the n-th one while it waits.
+func newWorkerPool(n int64) *workerPool {
+	p := &workerPool{}
+	p.cond = sync.NewCond(&p.mu)
+	for i := int64(1); i < n; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// work runs queued jobs until the pool is closed.
+func (p *workerPool) work() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		for len(p.jobs) == 0 && !p.closed {
+			p.cond.Wait()
+		}
+		if p.closed {
+			return
+		}
+		p.runNextLocked()
+	}
+}
+
+// runNextLocked pops a job and runs it with p.mu released.
+func (p *workerPool) runNextLocked() {
+	job := p.jobs[len(p.jobs)-1]
+	p.jobs[len(p.jobs)-1] = nil
+	p.jobs = p.jobs[:len(p.jobs)-1]
+	p.mu.Unlock()
+	job()
+	p.mu.Lock()
+}
+
+// close stops the workers. Jobs still queued are never run.
+func (p *workerPool) close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.cond.Broadcast()
+}
+
+// jobGroup is a set of jobs queued into a workerPool whose completion can be waited for,
+// much like errgroup.Group.
+type jobGroup struct {
+	pool    *workerPool
+	pending int // guarded by pool.mu
+	err     error
+}
+
+func (p *workerPool) group() *jobGroup {
+	return &jobGroup{pool: p}
+}
+
+// Go queues f. If another job of the group has already failed, f is not called.
+func (g *jobGroup) Go(f func() error) {
+	p := g.pool
+	p.mu.Lock()
+	g.pending++
+	p.jobs = append(p.jobs, func() {
+		p.mu.Lock()
+		failed := g.err != nil
+		p.mu.Unlock()
+
+		var err error
+		if !failed {
+			err = f()
+		}
+
+		p.mu.Lock()
+		if err != nil && g.err == nil {
+			g.err = err
+		}
+		g.pending--
+		p.mu.Unlock()
+		p.cond.Broadcast()
+	})
+	p.mu.Unlock()
+	p.cond.Signal()
+}
+
+// Wait runs queued jobs until all jobs of the group are finished,
+// and returns the first error reported by them.
+func (g *jobGroup) Wait() error {
+	p := g.pool
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for g.pending > 0 {
+		if len(p.jobs) > 0 {
+			p.runNextLocked()
+			continue
+		}
+		p.cond.Wait()
+	}
+	return g.err
+}
+
+// fcopyChunks copies a large file by splitting it into chunks
+// and queueing them into the worker pool, and helps the workers until all of them are copied.
+// The destination is allocated in advance as Preallocate does, or at least sized if it's not supported,
+// so that the chunks can be written in any order without running out of space halfway.
+func fcopyChunks(src io.ReaderAt, dst chunkWriter, size int64, opt Options) error {
+	allocated := false
+	if f, ok := dst.(*os.File); ok {
+		var err error
+		if allocated, err = fallocate(f, size); err != nil {
+			return err
+		}
+	}
+	if !allocated {
+		if err := dst.Truncate(size); err != nil {
+			return err
+		}
+	}
+
+	chunkSize := opt.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+	bufferSize := opt.CopyBufferSize
+	if bufferSize <= 0 {
+		bufferSize = defaultChunkBufferSize
+	}
+
+	group := opt.intent.pool.group()
+	for off := int64(0); off < size; off += chunkSize {
+		n := min(chunkSize, size-off)
+		group.Go(func() error {
+			reader := io.NewSectionReader(src, off, n)
+			writer := io.NewOffsetWriter(dst, off)
+			_, err := io.CopyBuffer(writer, reader, make([]byte, bufferSize))
+			return err
+		})
+	}
+	return group.Wait()
+}
@@ -0,0 +1,158 @@
+package copy_go
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// makeTree creates a tree under root with `width` files and `width` sub-directories
+// in every directory, down to `depth` levels.
+func makeTree(tb testing.TB, root string, width, depth int) {
+	tb.Helper()
+	if err := os.MkdirAll(root, 0755); err != nil {
+		tb.Fatal(err)
+	}
+	for i := 0; i < width; i++ {
+		name := filepath.Join(root, fmt.Sprintf("file-%d", i))
+		if err := os.WriteFile(name, []byte(name), 0644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if depth == 0 {
+		return
+	}
+	for i := 0; i < width; i++ {
+		makeTree(tb, filepath.Join(root, fmt.Sprintf("dir-%d", i)), width, depth-1)
+	}
+}
+
+func TestCopy_concurrent(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	makeTree(t, src, 4, 3)
+
+	for _, workers := range []int64{2, 3, 16} {
+		dst := filepath.Join(t.TempDir(), "dst")
+		if err := Copy(src, dst, Options{NumOfWorkers: workers}); err != nil {
+			t.Fatalf("workers=%d: %v", workers, err)
+		}
+		err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(src, path)
+			got, err := os.Stat(filepath.Join(dst, rel))
+			if err != nil {
+				return err
+			}
+			if got.IsDir() != info.IsDir() || got.Size() != info.Size() {
+				return fmt.Errorf("%s differs", rel)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Errorf("workers=%d: %v", workers, err)
+		}
+	}
+}
+
+func TestCopy_concurrentError(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	makeTree(t, src, 4, 2)
+
+	errBoom := errors.New("boom")
+	err := Copy(src, filepath.Join(t.TempDir(), "dst"), Options{
+		NumOfWorkers: 4,
+		Skip: func(src, dst string, info os.FileInfo) (bool, error) {
+			if strings.HasSuffix(src, filepath.Join("dir-1", "dir-2", "file-3")) {
+				return false, errBoom
+			}
+			return false, nil
+		},
+	})
+	if !errors.Is(err, errBoom) {
+		t.Errorf("Copy() = %v, want %v", err, errBoom)
+	}
+}
+
+func TestCopy_concurrentPreferSequential(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	makeTree(t, src, 3, 2)
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	err := Copy(src, dst, Options{
+		NumOfWorkers: 4,
+		PreferConcurrent: func(src, dst string) (bool, error) {
+			return filepath.Base(src) != "dir-0", nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "dir-0", "dir-2", "file-2")); err != nil {
+		t.Error(err)
+	}
+}
+
+func benchmarkCopy(b *testing.B, width, depth int, workers int64) {
+	src := filepath.Join(b.TempDir(), "src")
+	makeTree(b, src, width, depth)
+	dst := b.TempDir()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := Copy(src, filepath.Join(dst, fmt.Sprint(i)), Options{NumOfWorkers: workers}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCopy_wideTree(b *testing.B) {
+	for _, workers := range []int64{0, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			benchmarkCopy(b, 5000, 0, workers)
+		})
+	}
+}
+
+func BenchmarkCopy_deepTree(b *testing.B) {
+	for _, workers := range []int64{0, 4, 16} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			benchmarkCopy(b, 2, 9, workers)
+		})
+	}
+}
+
+func TestCopy_chunks(t *testing.T) {
+	data := make([]byte, 1<<20+123)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	src := filepath.Join(t.TempDir(), "large")
+	if err := os.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, chunkSize := range []int64{0, 4096, 100000, 1 << 20} {
+		dst := filepath.Join(t.TempDir(), "large")
+		err := Copy(src, dst, Options{
+			NumOfWorkers:   4,
+			ChunkThreshold: 1024,
+			ChunkSize:      chunkSize,
+		})
+		if err != nil {
+			t.Fatalf("chunkSize=%d: %v", chunkSize, err)
+		}
+		got, err := os.ReadFile(dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("chunkSize=%d: copied content differs", chunkSize)
+		}
+	}
+}
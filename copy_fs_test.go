@@ -0,0 +1,290 @@
+package copy_go
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+//go:embed test/data/example
+var exampleFS embed.FS
+
+type mapSys struct {
+	atime time.Time
+}
+
+func (s mapSys) AccessTime() time.Time { return s.atime }
+func (s mapSys) ChangeTime() time.Time { return s.atime }
+
+func TestCopy_mapFSPreserveTimes(t *testing.T) {
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	atime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"root":              {Mode: os.ModeDir | 0755, ModTime: mtime},
+		"root/plain.txt":    {Data: []byte("plain"), Mode: 0640, ModTime: mtime},
+		"root/sub/with.txt": {Data: []byte("with sys"), Mode: 0600, ModTime: mtime, Sys: mapSys{atime}},
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	err := Copy("root", dst, Options{
+		FS:            fsys,
+		PreserveTimes: true,
+		PreserveOwner: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{".", "plain.txt", filepath.Join("sub", "with.txt")} {
+		info, err := os.Stat(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !info.ModTime().Equal(mtime) {
+			t.Errorf("%s: mtime = %v, want %v", name, info.ModTime(), mtime)
+		}
+	}
+
+	info, _ := os.Stat(filepath.Join(dst, "plain.txt"))
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+	info, _ = os.Stat(filepath.Join(dst, "sub", "with.txt"))
+	if spec := getTimeSpec(info); !spec.Atime.Equal(atime) {
+		t.Errorf("atime = %v, want %v", spec.Atime, atime)
+	}
+}
+
+func TestCopy_embedFSPreserveTimes(t *testing.T) {
+	// embed.FS has no times, which are left as they are on creating
+	before := time.Now().Add(-time.Second)
+	dst := filepath.Join(t.TempDir(), "dst")
+	err := Copy("test/data/example", dst, Options{
+		FS:            exampleFS,
+		PreserveTimes: true,
+		PreserveOwner: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now().Add(time.Second)
+
+	want, _ := os.ReadFile("test/data/example/README.md")
+	got, err := os.ReadFile(filepath.Join(dst, "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("content = %q, want %q", got, want)
+	}
+	for _, name := range []string{".", "README.md"} {
+		info, err := os.Stat(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mtime := info.ModTime(); mtime.Before(before) || mtime.After(after) {
+			t.Errorf("%s: mtime = %v, want it left as created, between %v and %v", name, mtime, before, after)
+		}
+	}
+
+	t.Run("SysTimes", func(t *testing.T) {
+		atime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+		fsys := fstest.MapFS{
+			"root/README.md": {Data: want, Mode: 0644, Sys: mapSys{atime}},
+		}
+		before := time.Now().Add(-time.Second)
+		dst := filepath.Join(t.TempDir(), "dst")
+		if err := Copy("root", dst, Options{FS: fsys, PreserveTimes: true}); err != nil {
+			t.Fatal(err)
+		}
+		after := time.Now().Add(time.Second)
+
+		info, err := os.Stat(filepath.Join(dst, "README.md"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if spec := getTimeSpec(info); !spec.Atime.Equal(atime) {
+			t.Errorf("atime = %v, want %v", spec.Atime, atime)
+		}
+		if mtime := info.ModTime(); mtime.Before(before) || mtime.After(after) {
+			t.Errorf("mtime = %v, want it left as created, between %v and %v", mtime, before, after)
+		}
+	})
+}
+
+// statFS hides fs.ReadLinkFS of the FS.
+type statFS struct {
+	fs.StatFS
+}
+
+func TestCopy_mapFSSymlinks(t *testing.T) {
+	fsys := fstest.MapFS{
+		"root/dir/file.txt": {Data: []byte("file")},
+		"root/link":         {Data: []byte("dir/file.txt"), Mode: fs.ModeSymlink},
+		"root/dir/linkdir":  {Data: []byte("../dir"), Mode: fs.ModeSymlink},
+		"root/escape":       {Data: []byte("../../etc/passwd"), Mode: fs.ModeSymlink},
+	}
+	skipEscape := func(src, dst string, info os.FileInfo) (bool, error) {
+		return filepath.Base(src) == "escape", nil
+	}
+
+	t.Run("Shallow", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "dst")
+		result, err := CopyWithResult("root", dst, Options{FS: fsys})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Symlinks != 3 {
+			t.Errorf("result = %+v, want 3 symlinks", result)
+		}
+		if got, _ := os.Readlink(filepath.Join(dst, "link")); got != "dir/file.txt" {
+			t.Errorf("link = %q, want %q", got, "dir/file.txt")
+		}
+		if got, _ := os.ReadFile(filepath.Join(dst, "link")); string(got) != "file" {
+			t.Errorf("content via link = %q, want %q", got, "file")
+		}
+	})
+
+	t.Run("Deep", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "dst")
+		err := Copy("root", dst, Options{
+			FS:            fsys,
+			Skip:          skipEscape,
+			OnSymlink:     func(string) SymlinkAction { return Deep },
+			OnSymlinkLoop: func(string) SymlinkLoopAction { return LoopSkip },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Lstat(filepath.Join(dst, "link"))
+		if err != nil || !info.Mode().IsRegular() {
+			t.Errorf("link is not copied as a file: %v", err)
+		}
+		if _, err := os.Lstat(filepath.Join(dst, "dir", "linkdir")); !os.IsNotExist(err) {
+			t.Errorf("looping link is copied: %v", err)
+		}
+	})
+
+	t.Run("Deep out of FS", func(t *testing.T) {
+		var outOfTree *SymlinkOutOfTreeError
+		err := Copy("root", filepath.Join(t.TempDir(), "dst"), Options{
+			FS:        fsys,
+			OnSymlink: func(string) SymlinkAction { return Deep },
+			Skip: func(src, dst string, info os.FileInfo) (bool, error) {
+				return filepath.Base(src) == "dir", nil
+			},
+		})
+		if !errors.As(err, &outOfTree) {
+			t.Errorf("Copy() = %v, want *SymlinkOutOfTreeError", err)
+		}
+	})
+
+	t.Run("not supported", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "dst")
+		result, err := CopyWithResult("root", dst, Options{FS: statFS{fsys}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Symlinks != 0 || len(result.Skipped) != 3 {
+			t.Errorf("result = %+v, want 3 skipped symlinks", result)
+		}
+	})
+}
+
+type devSys uint64
+
+func (d devSys) Inode() (dev, ino, nlink uint64) { return uint64(d), 0, 1 }
+
+type inodeSys uint64
+
+func (i inodeSys) Inode() (dev, ino, nlink uint64) { return 1, uint64(i), 2 }
+
+// vanishFS is a MapFS whose file gone has vanished after being listed.
+type vanishFS struct {
+	fstest.MapFS
+	gone string
+}
+
+func (v vanishFS) Open(name string) (fs.File, error) {
+	if name == v.gone {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return v.MapFS.Open(name)
+}
+
+func TestCopy_mapFSHardlinkVanished(t *testing.T) {
+	fsys := vanishFS{fstest.MapFS{
+		"root/a": {Data: []byte("hello"), Sys: inodeSys(1)},
+		"root/b": {Data: []byte("hello"), Sys: inodeSys(1)},
+	}, "root/a"}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	result, err := CopyWithResult("root", dst, Options{FS: fsys, PreserveHardlinks: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Files != 1 || result.Hardlinks != 0 {
+		t.Errorf("Files = %d, Hardlinks = %d, want 1, 0", result.Files, result.Hardlinks)
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "a")); !os.IsNotExist(err) {
+		t.Errorf("a: Lstat() = %v, want not exist", err)
+	}
+	if b, err := os.ReadFile(filepath.Join(dst, "b")); err != nil || string(b) != "hello" {
+		t.Errorf("b = %q, %v, want %q", b, err, "hello")
+	}
+}
+
+func TestCopy_mapFSOneFileSystem(t *testing.T) {
+	fsys := fstest.MapFS{
+		"root":              {Mode: fs.ModeDir | 0755, Sys: devSys(1)},
+		"root/a.txt":        {Data: []byte("a"), Sys: devSys(1)},
+		"root/sub":          {Mode: fs.ModeDir | 0755, Sys: devSys(1)},
+		"root/sub/b.txt":    {Data: []byte("b"), Sys: devSys(1)},
+		"root/mnt":          {Mode: fs.ModeDir | 0755, Sys: devSys(2)},
+		"root/mnt/c.txt":    {Data: []byte("c"), Sys: devSys(2)},
+		"root/sub/deep":     {Mode: fs.ModeDir | 0755, Sys: devSys(1)},
+		"root/sub/deep/d":   {Data: []byte("d"), Sys: devSys(1)},
+		"root/sub/deep/mnt": {Mode: fs.ModeDir | 0755, Sys: devSys(3)},
+	}
+
+	for name, c := range map[string]struct {
+		opt  Options
+		want map[string]bool
+	}{
+		"skip": {
+			Options{OneFileSystem: true},
+			map[string]bool{"a.txt": true, "sub/b.txt": true, "sub/deep/d": true, "mnt": false, "sub/deep/mnt": false},
+		},
+		"empty": {
+			Options{OneFileSystem: true, OnMountPoint: EmptyMountPoint, NumOfWorkers: 4},
+			map[string]bool{"sub/deep/d": true, "mnt": true, "mnt/c.txt": false, "sub/deep/mnt": true},
+		},
+		"max depth": {
+			Options{MaxDepth: 1},
+			map[string]bool{"a.txt": true, "sub": true, "mnt": true, "sub/b.txt": false, "mnt/c.txt": false},
+		},
+		"max depth with mount points": {
+			Options{MaxDepth: 2, OneFileSystem: true, CheckFreeSpace: true},
+			map[string]bool{"sub/b.txt": true, "sub/deep": true, "sub/deep/d": false, "mnt": false},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dst := filepath.Join(t.TempDir(), "dst")
+			c.opt.FS = fsys
+			if err := Copy("root", dst, c.opt); err != nil {
+				t.Fatal(err)
+			}
+			for rel, want := range c.want {
+				_, err := os.Lstat(filepath.Join(dst, filepath.FromSlash(rel)))
+				if got := err == nil; got != want {
+					t.Errorf("%s: copied = %v, want %v", rel, got, want)
+				}
+			}
+		})
+	}
+}
@@ -3,14 +3,10 @@
 package copy_go
 
 import (
-	"os"
-	"path/filepath"
+	"io/fs"
 	"syscall"
 )
 
-func pcopy(dst string, info os.FileInfo) error {
-	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
-		return err
-	}
-	return syscall.Mkfifo(dst, uint32(info.Mode()))
+func mkfifo(name string, perm fs.FileMode) error {
+	return syscall.Mkfifo(name, uint32(perm))
 }
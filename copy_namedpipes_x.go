@@ -3,10 +3,12 @@
 package copy_go
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 )
 
 // windows does not support named pipes
-func pcopy(dst string, info os.FileInfo) error {
-	return nil
+func mkfifo(name string, perm fs.FileMode) error {
+	return &os.PathError{Op: "mkfifo", Path: name, Err: errors.ErrUnsupported}
 }
@@ -0,0 +1,45 @@
+//go:build linux || darwin
+
+package copy_go
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// mknod creates a device or a socket of the type given by mode, with the device number dev.
+func mknod(name string, mode fs.FileMode, dev uint64) error {
+	m := uint32(mode.Perm())
+	switch {
+	case mode&fs.ModeSocket != 0:
+		m |= unix.S_IFSOCK
+	case mode&fs.ModeCharDevice != 0:
+		m |= unix.S_IFCHR
+	default:
+		m |= unix.S_IFBLK
+	}
+	if err := unix.Mknod(name, m, int(dev)); err != nil {
+		return &os.PathError{Op: "mknod", Path: name, Err: err}
+	}
+	return nil
+}
+
+func statRdev(info fs.FileInfo) (uint64, bool) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Rdev), true
+	}
+	return 0, false
+}
+
+// devNumbers splits a device number into its major and minor numbers.
+func devNumbers(dev uint64) (major, minor int64) {
+	return int64(unix.Major(dev)), int64(unix.Minor(dev))
+}
+
+// makeDev makes a device number from its major and minor numbers.
+func makeDev(major, minor int64) uint64 {
+	return unix.Mkdev(uint32(major), uint32(minor))
+}
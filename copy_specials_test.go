@@ -0,0 +1,88 @@
+//go:build linux
+
+package copy_go
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCopyWithResult_specials(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", filepath.Join(src, "sock"))
+	if err != nil {
+		t.Skip("cannot create unix socket:", err)
+	}
+	defer l.Close()
+
+	t.Run("without Specials", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "dst")
+		result, err := CopyWithResult(src, dst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Sockets != 0 || len(result.Skipped) != 1 {
+			t.Errorf("result = %+v, want a skipped socket", result)
+		}
+		if _, err := os.Lstat(filepath.Join(dst, "sock")); !os.IsNotExist(err) {
+			t.Errorf("socket is created: %v", err)
+		}
+	})
+
+	t.Run("recreate socket", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "dst")
+		result, err := CopyWithResult(src, dst, Options{Specials: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Sockets != 1 || result.Dirs != 1 {
+			t.Errorf("result = %+v, want a socket and a dir", result)
+		}
+		info, err := os.Lstat(filepath.Join(dst, "sock"))
+		if err != nil || info.Mode()&os.ModeSocket == 0 {
+			t.Errorf("socket is not created: %v", err)
+		}
+	})
+
+	t.Run("skip socket", func(t *testing.T) {
+		dst := filepath.Join(t.TempDir(), "dst")
+		result, err := CopyWithResult(src, dst, Options{
+			Specials: true,
+			OnSocket: func(string) SocketAction { return SkipSocket },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Sockets != 0 || len(result.Skipped) != 1 {
+			t.Errorf("result = %+v, want a skipped socket", result)
+		}
+	})
+}
+
+func TestCopyWithResult_devices(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "null")
+	result, err := CopyWithResult("/dev/null", dst, Options{Specials: true})
+	if err != nil {
+		t.Skip("cannot create device:", err)
+	}
+	if result.CharDevices != 1 {
+		t.Errorf("result = %+v, want a char device", result)
+	}
+	info, err := os.Lstat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeCharDevice == 0 {
+		t.Errorf("mode = %v, want char device", info.Mode())
+	}
+	orig, _ := os.Lstat("/dev/null")
+	if got, want := info.Sys().(*syscall.Stat_t).Rdev, orig.Sys().(*syscall.Stat_t).Rdev; got != want {
+		t.Errorf("rdev = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,30 @@
+//go:build !(linux || darwin)
+
+package copy_go
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+func mknod(name string, mode fs.FileMode, dev uint64) error {
+	return &os.PathError{Op: "mknod", Path: name, Err: errors.ErrUnsupported}
+}
+
+func statRdev(info fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
+
+// devNumbers splits a device number into its major and minor numbers, as glibc does.
+func devNumbers(dev uint64) (major, minor int64) {
+	major = int64((dev>>8)&0xfff | (dev>>32)&^0xfff)
+	minor = int64(dev&0xff | (dev>>12)&^0xff)
+	return major, minor
+}
+
+// makeDev makes a device number from its major and minor numbers, as glibc does.
+func makeDev(major, minor int64) uint64 {
+	ma, mi := uint64(major), uint64(minor)
+	return (ma&0xfff)<<8 | (ma&^0xfff)<<32 | mi&0xff | (mi&^0xff)<<12
+}
@@ -0,0 +1,41 @@
+//go:build linux || darwin || freebsd
+
+package copy_go
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+// unknownInodes is reported by availableSpace when the filesystem doesn't limit inodes.
+const unknownInodes = ^uint64(0)
+
+// availableSpace reports the bytes and inodes available to an unprivileged user
+// on the filesystem which path is, or will be, created on.
+func availableSpace(path string) (bytes, inodes uint64, ok bool, err error) {
+	for {
+		if _, err = os.Stat(path); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return 0, 0, false, err
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return 0, 0, false, nil
+		}
+		path = parent
+	}
+
+	var st unix.Statfs_t
+	if err = unix.Statfs(path, &st); err != nil {
+		return 0, 0, false, err
+	}
+	bytes = uint64(st.Bavail) * uint64(st.Bsize)
+	inodes = uint64(st.Ffree)
+	if st.Files == 0 {
+		inodes = unknownInodes // e.g. btrfs does not have a fixed number of inodes
+	}
+	return bytes, inodes, true, nil
+}
@@ -0,0 +1,125 @@
+package copy_go
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// syncBatchSize is how many directories are queued before they are fsynced together.
+const syncBatchSize = 256
+
+// syncer makes the copies durable, see Options.Durability.
+// Directories are queued when all the entries in them are written,
+// and fsynced in batches by the workers. A nil *syncer does nothing.
+type syncer struct {
+	mu      sync.Mutex
+	pending []string
+	pool    *workerPool
+	parents []string // of dst, to be fsynced at the end
+	osfs    bool     // directories can be fsynced only on the OS filesystem
+	syncfs  bool     // the whole filesystem is synced at the end instead
+
+	// onSync is called after a directory is fsynced, or the filesystem is synced,
+	// with op "fsync" or "syncfs" and the name, only by tests.
+	onSync func(op, name string)
+}
+
+// newSyncer returns the syncer of the copy to dst, before anything is written.
+func newSyncer(dst string, opt Options) *syncer {
+	if opt.Durability == NotDurable {
+		return nil
+	}
+	s := &syncer{
+		pool:   opt.intent.pool,
+		onSync: opt.intent.onSync,
+		osfs:   isOSFS(opt.DestFS),
+		syncfs: opt.Durability == SyncFilesystem && syncfsSupported && isOSFS(opt.DestFS),
+	}
+	if s.osfs && !s.syncfs {
+		s.parents = rootParents(dst) // before MkdirAll creates them
+	}
+	return s
+}
+
+// syncFile tells if fcopy should fsync the file.
+func (s *syncer) syncFile() bool {
+	return s != nil && !s.syncfs
+}
+
+// dir queues a directory whose entries are all written,
+// and fsyncs the queued ones if there are enough of them.
+func (s *syncer) dir(name string) error {
+	if s == nil || s.syncfs || !s.osfs {
+		return nil
+	}
+	var batch []string
+	s.mu.Lock()
+	s.pending = append(s.pending, name)
+	if len(s.pending) >= syncBatchSize {
+		batch, s.pending = s.pending, nil
+	}
+	s.mu.Unlock()
+	return s.fsyncDirs(batch)
+}
+
+// finish fsyncs the directories left and the parents of dst,
+// or syncs the whole filesystem of dst by syncfs(2).
+func (s *syncer) finish(dst string) error {
+	if s == nil || !s.osfs {
+		return nil
+	}
+	if s.syncfs {
+		if err := syncfs(dst); err != nil {
+			return err
+		}
+		if s.onSync != nil {
+			s.onSync("syncfs", dst)
+		}
+		return nil
+	}
+	s.mu.Lock()
+	batch := append(s.pending, s.parents...)
+	s.pending = nil
+	s.mu.Unlock()
+	return s.fsyncDirs(batch)
+}
+
+// fsyncDirs fsyncs the directories by the workers in parallel.
+func (s *syncer) fsyncDirs(names []string) error {
+	if s.pool == nil || len(names) < 2 {
+		for _, name := range names {
+			if err := s.fsyncDir(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	group := s.pool.group()
+	for _, name := range names {
+		group.Go(func() error { return s.fsyncDir(name) })
+	}
+	return group.Wait()
+}
+
+func (s *syncer) fsyncDir(name string) error {
+	if err := fsyncDir(name); err != nil {
+		return err
+	}
+	if s.onSync != nil {
+		s.onSync("fsync", name)
+	}
+	return nil
+}
+
+// rootParents returns the parents of dst to be created for it,
+// and the existing one containing them, whose entries the copy changes.
+func rootParents(dst string) []string {
+	var parents []string
+	for dir := filepath.Dir(dst); ; dir = filepath.Dir(dir) {
+		parents = append(parents, dir)
+		if _, err := os.Lstat(dir); err == nil || filepath.Dir(dir) == dir {
+			return parents
+		}
+	}
+}
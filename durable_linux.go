@@ -0,0 +1,27 @@
+//go:build linux
+
+package copy_go
+
+import (
+	"os"
+	"path/filepath"
+
+	"golang.org/x/sys/unix"
+)
+
+const syncfsSupported = true
+
+// syncfs syncs the whole filesystem containing name by syncfs(2).
+func syncfs(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		if f, err = os.Open(filepath.Dir(name)); err != nil {
+			return err // e.g. dst is a file not readable
+		}
+	}
+	defer f.Close()
+	if err := unix.Syncfs(int(f.Fd())); err != nil {
+		return &os.PathError{Op: "syncfs", Path: f.Name(), Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package copy_go
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestRootParents(t *testing.T) {
+	root := t.TempDir()
+	dst := filepath.Join(root, "a", "b", "dst")
+	want := []string{filepath.Join(root, "a", "b"), filepath.Join(root, "a"), root}
+	if got := rootParents(dst); !slices.Equal(got, want) {
+		t.Errorf("rootParents() = %v, want %v", got, want)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := rootParents(dst); !slices.Equal(got, want[:1]) {
+		t.Errorf("rootParents() = %v, want %v", got, want[:1])
+	}
+}
+
+// recordSyncs makes opt record what the syncer syncs, in order, which the returned function returns.
+func recordSyncs(opt Options) (Options, func() []string) {
+	var mu sync.Mutex
+	var synced []string
+	opt.intent.onSync = func(op, name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		synced = append(synced, op+" "+name)
+	}
+	return opt, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(synced)
+	}
+}
+
+// dirsOf returns "fsync <dir>" of all the directories in root.
+func dirsOf(t *testing.T, root string) []string {
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			dirs = append(dirs, "fsync "+path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dirs
+}
+
+func TestCopy_durability(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	makeTree(t, src, 2, 2)
+
+	for _, durability := range []DurabilityAction{FsyncEach, SyncFilesystem} {
+		for _, workers := range []int64{0, 4} {
+			opt, synced := recordSyncs(Options{Durability: durability, NumOfWorkers: workers})
+			root := t.TempDir()
+			dst := filepath.Join(root, "parent", "dst")
+			result, err := CopyWithResult(src, dst, opt)
+			if err != nil {
+				t.Fatalf("durability %d, workers %d: %v", durability, workers, err)
+			}
+			if result.Dirs != 7 || result.Files != 14 {
+				t.Errorf("durability %d, workers %d: result = %+v", durability, workers, result)
+			}
+
+			// every directory copied, the parent created, and the existing one containing it
+			want := append(dirsOf(t, dst), "fsync "+filepath.Join(root, "parent"), "fsync "+root)
+			if durability == SyncFilesystem && syncfsSupported {
+				want = []string{"syncfs " + dst}
+			}
+			got := synced()
+			slices.Sort(got)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("durability %d, workers %d: synced %q, want %q", durability, workers, got, want)
+			}
+		}
+	}
+
+	opt, synced := recordSyncs(Options{DestFS: NewMemFS(), Durability: FsyncEach, NumOfWorkers: 4})
+	if err := Copy(src, "dst", opt); err != nil {
+		t.Fatal(err)
+	}
+	if got := synced(); len(got) != 0 {
+		t.Errorf("synced %q on MemFS", got)
+	}
+}
+
+func TestCopy_durabilityInBatches(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	makeTree(t, src, 1, syncBatchSize+10)
+
+	opt, synced := recordSyncs(Options{Durability: FsyncEach, NumOfWorkers: 4})
+	dst := filepath.Join(t.TempDir(), "dst")
+	result, err := CopyWithResult(src, dst, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Dirs != syncBatchSize+11 {
+		t.Errorf("result = %+v", result)
+	}
+
+	// a full batch is fsynced during the copy, and the rest with dst and its parent at the end
+	got := synced()
+	if len(got) != syncBatchSize+12 {
+		t.Fatalf("%d fsynced, want %d", len(got), syncBatchSize+12)
+	}
+	for _, name := range []string{dst, filepath.Dir(dst)} {
+		if i := slices.Index(got, "fsync "+name); i < syncBatchSize {
+			t.Errorf("%s is fsynced %dth, want after the first batch", name, i)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package copy_go
+
+import (
+	"errors"
+	"os"
+)
+
+const syncfsSupported = false
+
+func syncfs(name string) error {
+	return &os.PathError{Op: "syncfs", Path: name, Err: errors.ErrUnsupported}
+}
@@ -0,0 +1,92 @@
+package copy_go
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// Entry is what the callbacks of Options ending with "Entry" are given,
+// to know where the entry is in the tree without stripping the roots from the paths.
+type Entry struct {
+	Src     string      // path of the source entry, as the other callbacks are given
+	Dst     string      // path of the destination entry, as the other callbacks are given
+	SrcRoot string      // src given to Copy
+	DstRoot string      // dst given to Copy
+	Rel     string      // slash-separated path relative to the roots, "." for the roots themselves
+	Depth   int         // 0 for the roots, 1 for the entries in them, and so on
+	Info    fs.FileInfo // of the source entry, not following symlinks
+
+	dfs WritableFS
+}
+
+// newEntry makes the Entry of src at the position of the tree in opt.
+func newEntry(src, dst string, info os.FileInfo, opt Options) *Entry {
+	return &Entry{
+		Src:     src,
+		Dst:     dst,
+		SrcRoot: opt.intent.src,
+		DstRoot: opt.intent.dst,
+		Rel:     opt.intent.rel,
+		Depth:   depthOf(opt.intent.rel),
+		Info:    info,
+		dfs:     opt.DestFS,
+	}
+}
+
+// DirEntry returns Info as fs.DirEntry.
+func (e *Entry) DirEntry() fs.DirEntry {
+	return fs.FileInfoToDirEntry(e.Info)
+}
+
+// DstInfo returns the Lstat of Dst in Options.DestFS, which is an error of fs.ErrNotExist if it doesn't exist yet.
+func (e *Entry) DstInfo() (fs.FileInfo, error) {
+	return e.dfs.Lstat(e.Dst)
+}
+
+func depthOf(rel string) int {
+	if rel == "." || rel == "" {
+		return 0
+	}
+	return strings.Count(rel, "/") + 1
+}
+
+// child returns opt for the entry name in the directory being copied by opt.
+func (opt Options) child(name string) Options {
+	opt.intent.rel = path.Join(opt.intent.rel, name)
+	return opt
+}
+
+// shouldSkip calls SkipEntry, or Skip if not given.
+func shouldSkip(src, dst string, info os.FileInfo, opt Options) (bool, error) {
+	if opt.SkipEntry != nil {
+		return opt.SkipEntry(newEntry(src, dst, info, opt))
+	}
+	if opt.Skip != nil {
+		return opt.Skip(src, dst, info)
+	}
+	return false, nil
+}
+
+// renameDestination calls RenameDestinationEntry, or RenameDestination if not given.
+func renameDestination(src, dst string, info os.FileInfo, opt Options) (string, error) {
+	if opt.RenameDestinationEntry != nil {
+		return opt.RenameDestinationEntry(newEntry(src, dst, info, opt))
+	}
+	if opt.RenameDestination != nil {
+		return opt.RenameDestination(src, dst)
+	}
+	return dst, nil
+}
+
+// dirExistsAction calls OnDirExistsEntry, or OnDirExists if not given.
+func dirExistsAction(srcdir, dstdir string, info os.FileInfo, opt Options) DirExistsAction {
+	if opt.OnDirExistsEntry != nil {
+		return opt.OnDirExistsEntry(newEntry(srcdir, dstdir, info, opt))
+	}
+	if opt.OnDirExists != nil {
+		return opt.OnDirExists(srcdir, dstdir)
+	}
+	return Merge
+}
@@ -0,0 +1,109 @@
+package copy_go
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCopy_entryCallbacks(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	for _, name := range []string{"a.txt", "sub/b.txt", "sub/deep/c.txt", "sub/deep/skipped.txt"} {
+		name = filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte("hello"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := os.MkdirAll(filepath.Join(dst, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, workers := range []int64{0, 4} {
+		var mu sync.Mutex
+		seen := map[string]int{}
+		existing := map[string]bool{}
+		err := Copy(src, dst, Options{
+			NumOfWorkers: workers,
+			SkipEntry: func(e *Entry) (bool, error) {
+				if e.SrcRoot != src || e.DstRoot != dst {
+					t.Errorf("roots = %q, %q", e.SrcRoot, e.DstRoot)
+				}
+				if e.Src != filepath.Join(src, filepath.FromSlash(e.Rel)) || e.Info.Name() != e.DirEntry().Name() {
+					t.Errorf("%s: Rel = %q", e.Src, e.Rel)
+				}
+				mu.Lock()
+				seen[e.Rel] = e.Depth
+				mu.Unlock()
+				return strings.HasPrefix(e.Info.Name(), "skipped"), nil
+			},
+			RenameDestinationEntry: func(e *Entry) (string, error) {
+				if e.Depth == 3 {
+					return e.Dst + ".renamed", nil
+				}
+				return e.Dst, nil
+			},
+			OnDirExistsEntry: func(e *Entry) DirExistsAction {
+				_, err := e.DstInfo()
+				mu.Lock()
+				existing[e.Rel] = err == nil
+				mu.Unlock()
+				return Merge
+			},
+			PreferConcurrentEntry: func(e *Entry) (bool, error) {
+				return e.Depth < 2, nil
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := map[string]int{"a.txt": 1, "sub": 1, "sub/b.txt": 2, "sub/deep": 2, "sub/deep/c.txt": 3, "sub/deep/skipped.txt": 3}
+		if len(seen) != len(want) {
+			t.Errorf("seen = %v, want %v", seen, want)
+		}
+		for rel, depth := range want {
+			if got, ok := seen[rel]; !ok || got != depth {
+				t.Errorf("%s: Depth = %d, %v, want %d", rel, got, ok, depth)
+			}
+		}
+		if !existing["sub"] {
+			t.Errorf("existing = %v, want sub", existing)
+		}
+		if _, err := os.Stat(filepath.Join(dst, "sub", "deep", "c.txt.renamed")); err != nil {
+			t.Error(err)
+		}
+		if _, err := os.Stat(filepath.Join(dst, "sub", "deep", "skipped.txt.renamed")); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("skipped.txt is copied: %v", err)
+		}
+	}
+}
+
+func TestCopy_entryCallbacksPreferred(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "dst")
+	err := Copy(src, dst, Options{
+		Skip: func(src, dst string, info os.FileInfo) (bool, error) {
+			return true, nil
+		},
+		SkipEntry: func(e *Entry) (bool, error) {
+			return false, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "a.txt")); err != nil {
+		t.Error(err)
+	}
+}
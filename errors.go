@@ -0,0 +1,91 @@
+package copy_go
+
+import "fmt"
+
+// PreallocateError is returned when the space for a destination file cannot be reserved
+// before copying its contents, e.g. because of no space left on the device.
+// Use errors.Is(err, syscall.ENOSPC) to tell the reason.
+type PreallocateError struct {
+	Path string
+	Size int64
+	Err  error
+}
+
+func (e *PreallocateError) Error() string {
+	return fmt.Sprintf("preallocate %s (%d bytes): %v", e.Path, e.Size, e.Err)
+}
+
+func (e *PreallocateError) Unwrap() error {
+	return e.Err
+}
+
+// InsufficientSpaceError is returned before copying anything
+// when Options.CheckFreeSpace is set and the destination filesystem can't hold the tree.
+type InsufficientSpaceError struct {
+	Path            string
+	NeededBytes     uint64
+	AvailableBytes  uint64
+	NeededInodes    uint64
+	AvailableInodes uint64
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("not enough space on the filesystem of %s: %d bytes and %d inodes needed, %d bytes and %d inodes available",
+		e.Path, e.NeededBytes, e.NeededInodes, e.AvailableBytes, e.AvailableInodes)
+}
+
+// SymlinkLoopError is returned when a symlink copied by Deep
+// points to itself or to a directory containing it.
+type SymlinkLoopError struct {
+	Src    string
+	Target string
+}
+
+func (e *SymlinkLoopError) Error() string {
+	return fmt.Sprintf("symlink loop: %s -> %s", e.Src, e.Target)
+}
+
+// SymlinkOutOfTreeError is returned when a symlink pointing outside of the source tree
+// is rejected by Options.OutOfTreeSymlink.
+type SymlinkOutOfTreeError struct {
+	Src    string
+	Target string
+}
+
+func (e *SymlinkOutOfTreeError) Error() string {
+	return fmt.Sprintf("symlink out of tree: %s -> %s", e.Src, e.Target)
+}
+
+// UnsafePathError is returned in Contained mode,
+// when a destination would be written through a symlink or outside of the destination root.
+type UnsafePathError struct {
+	Path   string
+	Reason string
+}
+
+func (e *UnsafePathError) Error() string {
+	return fmt.Sprintf("unsafe destination path %s: %s", e.Path, e.Reason)
+}
+
+// UnmappedOwnerError is returned by the OwnerMapper of MapOwnerRanges,
+// when the owner of a source entry is not in the ranges.
+type UnmappedOwnerError struct {
+	Uid int
+	Gid int
+}
+
+func (e *UnmappedOwnerError) Error() string {
+	return fmt.Sprintf("owner %d:%d is not mapped", e.Uid, e.Gid)
+}
+
+// UnsafeArchiveError is returned by OpenTarFS and ReadTarFS,
+// when an entry of the archive would be extracted outside of the destination,
+// e.g. by an absolute path, "..", or a path through a symlink.
+type UnsafeArchiveError struct {
+	Name   string
+	Reason string
+}
+
+func (e *UnsafeArchiveError) Error() string {
+	return fmt.Sprintf("unsafe archive entry %s: %s", e.Name, e.Reason)
+}
@@ -50,3 +50,25 @@ func ExampleOptions() {
 	// Skipped: true
 
 }
+
+func ExampleFilter() {
+
+	filter := NewFilter(`.git-example/`, `*.log`)
+	filter.IgnoreFiles(`.gitignore`)
+
+	err := Copy(`test/data/example`, `test/data.copy/example_with_filter`, Options{
+		Skip: filter.Skip(nil, `test/data/example`),
+	})
+	defer func() {
+		_ = os.RemoveAll(`test/data.copy`)
+	}()
+	fmt.Println("Error:", err)
+
+	_, err = os.Stat(`test/data.copy/example_with_filter/.git-example`)
+	fmt.Println("Skipped:", os.IsNotExist(err))
+
+	// Output:
+	// Error: <nil>
+	// Skipped: true
+
+}
@@ -0,0 +1,366 @@
+package copy_go
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Filter is an ordered list of include and exclude rules with the semantics of .gitignore,
+// which makes Options.Skip by Skip. e.g.,
+//
+//	filter := NewFilter("*.log", "!important.log", "/build/", "**/node_modules/")
+//	filter.IgnoreFiles(".gitignore", ".dockerignore")
+//	err := Copy(src, dst, Options{Skip: filter.Skip(nil, src)})
+//
+// A rule is a pattern of a path relative to the copy root, or to the directory of its ignore file:
+//   - "*", "?" and "[...]" match anything but "/", and "**" matches any number of directories,
+//   - a pattern having "/" at the beginning or in the middle is anchored, otherwise it matches at any level,
+//   - a pattern ending with "/" matches only directories,
+//   - a pattern beginning with "!" includes what the rules before it exclude.
+//
+// The rule matching last decides, and an excluded directory is never walked into,
+// so that nothing in it can be included again, as git does.
+type Filter struct {
+	rules       []filterRule
+	ignoreFiles []string
+}
+
+// filterRule is a compiled pattern.
+type filterRule struct {
+	re      *regexp.Regexp
+	base    string // directory of the ignore file relative to the root, "" for the root
+	include bool
+	dirOnly bool
+}
+
+// NewFilter returns a Filter of the rules, given as the lines of .gitignore.
+func NewFilter(rules ...string) *Filter {
+	f := &Filter{}
+	for _, line := range rules {
+		if rule, ok := parseFilterRule(line, ""); ok {
+			f.rules = append(f.rules, rule)
+		}
+	}
+	return f
+}
+
+// Exclude adds the patterns as exclude rules.
+func (f *Filter) Exclude(patterns ...string) {
+	for _, pattern := range patterns {
+		if rule, ok := compileFilterRule(pattern, ""); ok {
+			f.rules = append(f.rules, rule)
+		}
+	}
+}
+
+// Include adds the patterns as include rules, which are the same as the ones beginning with "!".
+func (f *Filter) Include(patterns ...string) {
+	for _, pattern := range patterns {
+		if rule, ok := compileFilterRule(pattern, ""); ok {
+			rule.include = true
+			f.rules = append(f.rules, rule)
+		}
+	}
+}
+
+// LoadIgnoreFile adds the rules of an ignore file, e.g. .gitignore, relative to the copy root.
+func (f *Filter) LoadIgnoreFile(name string) error {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	f.rules = append(f.rules, parseIgnoreFile(data, "")...)
+	return nil
+}
+
+// IgnoreFiles makes Skip read the ignore files of the names, e.g. ".gitignore",
+// in every directory walked into. Their rules are relative to the directory,
+// and are evaluated after the rules of the parent directories and the rules added to the Filter.
+func (f *Filter) IgnoreFiles(names ...string) {
+	f.ignoreFiles = append(f.ignoreFiles, names...)
+}
+
+// Skip returns a function for Options.Skip, which skips the entries excluded by the rules,
+// with evaluating their paths relative to root, which is src given to Copy.
+// The ignore files are read from fsys, which is Options.FS, or from the OS filesystem if nil.
+// Entries out of root, which are reached through symlinks copied by Deep, are never skipped.
+func (f *Filter) Skip(fsys fs.FS, root string) func(src, dst string, info os.FileInfo) (bool, error) {
+	if fsys == nil {
+		root = assureHomeDir(root) // as Copy does to src
+	}
+	loaded := &ignoreFileCache{filter: f, fsys: fsys, root: root, rules: map[string][]filterRule{}}
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		rel, ok := relativePath(fsys, root, src)
+		if !ok {
+			return false, nil
+		}
+		rules, err := loaded.rulesFor(path.Dir(rel))
+		if err != nil {
+			return false, err
+		}
+		return matchFilterRules(rules, rel, info.IsDir()), nil
+	}
+}
+
+// Excluded tells if a path relative to the copy root is excluded by the rules added to the Filter,
+// without any ignore files.
+func (f *Filter) Excluded(rel string, isDir bool) bool {
+	return matchFilterRules(f.rules, path.Clean(filepath.ToSlash(rel)), isDir)
+}
+
+// relativePath returns src relative to root, slash-separated, if src is under root.
+func relativePath(fsys fs.FS, root, src string) (string, bool) {
+	var rel string
+	if fsys != nil {
+		root, src = path.Clean(root), path.Clean(src)
+		switch {
+		case root == ".":
+			rel = src
+		case strings.HasPrefix(src, root+"/"):
+			rel = src[len(root)+1:]
+		default:
+			return "", false
+		}
+	} else {
+		var err error
+		if rel, err = filepath.Rel(root, src); err != nil {
+			return "", false
+		}
+		rel = filepath.ToSlash(rel)
+	}
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", false
+	}
+	return rel, true
+}
+
+// matchFilterRules tells if the path is excluded by the rules.
+func matchFilterRules(rules []filterRule, rel string, isDir bool) bool {
+	excluded := false
+	for _, rule := range rules {
+		if rule.match(rel, isDir) {
+			excluded = !rule.include
+		}
+	}
+	return excluded
+}
+
+func (r filterRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if r.base != "" {
+		var ok bool
+		if rel, ok = strings.CutPrefix(rel, r.base+"/"); !ok {
+			return false
+		}
+	}
+	return r.re.MatchString(rel)
+}
+
+// ignoreFileCache reads the ignore files of each directory once, for the copy by the workers.
+type ignoreFileCache struct {
+	filter *Filter
+	fsys   fs.FS
+	root   string
+	mu     sync.Mutex
+	rules  map[string][]filterRule // of the directory relative to root, including its ancestors
+}
+
+// rulesFor returns all the rules to evaluate the entries in dir.
+func (c *ignoreFileCache) rulesFor(dir string) ([]filterRule, error) {
+	if len(c.filter.ignoreFiles) == 0 {
+		return c.filter.rules, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rulesForLocked(dir)
+}
+
+func (c *ignoreFileCache) rulesForLocked(dir string) ([]filterRule, error) {
+	if rules, ok := c.rules[dir]; ok {
+		return rules, nil
+	}
+
+	var parent []filterRule
+	base := dir
+	if dir == "." {
+		parent, base = c.filter.rules, ""
+	} else {
+		var err error
+		if parent, err = c.rulesForLocked(path.Dir(dir)); err != nil {
+			return nil, err
+		}
+	}
+
+	rules := parent[:len(parent):len(parent)] // not to share the tail with the siblings
+	for _, name := range c.filter.ignoreFiles {
+		data, err := c.readFile(path.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, parseIgnoreFile(data, base)...)
+	}
+	c.rules[dir] = rules
+	return rules, nil
+}
+
+func (c *ignoreFileCache) readFile(rel string) ([]byte, error) {
+	if c.fsys != nil {
+		return fs.ReadFile(c.fsys, path.Join(c.root, rel))
+	}
+	return os.ReadFile(filepath.Join(c.root, filepath.FromSlash(rel)))
+}
+
+// parseIgnoreFile parses the lines of an ignore file in the directory base.
+func parseIgnoreFile(data []byte, base string) []filterRule {
+	var rules []filterRule
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if rule, ok := parseFilterRule(scanner.Text(), base); ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// parseFilterRule parses a line of .gitignore.
+// Blank lines and comments beginning with "#" are not rules.
+func parseFilterRule(line, base string) (filterRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return filterRule{}, false
+	}
+	include := false
+	if strings.HasPrefix(line, "!") {
+		include, line = true, line[1:]
+	}
+	rule, ok := compileFilterRule(line, base)
+	rule.include = include
+	return rule, ok
+}
+
+// compileFilterRule compiles a pattern without "!" into a regexp.
+func compileFilterRule(pattern, base string) (filterRule, bool) {
+	rule := filterRule{base: base}
+	if strings.HasSuffix(pattern, "/") {
+		rule.dirOnly, pattern = true, strings.TrimRight(pattern, "/")
+	}
+	if pattern == "" {
+		return filterRule{}, false
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	if anchored := strings.Contains(pattern, "/"); anchored {
+		pattern = strings.TrimPrefix(pattern, "/")
+	} else {
+		expr.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/") && (i == 0 || pattern[i-1] == '/'):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case pattern[i:] == "**" && i > 0 && pattern[i-1] == '/':
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '[':
+			class, n, ok := compileFilterClass(pattern[i:])
+			if !ok {
+				expr.WriteString(`\[`)
+				continue
+			}
+			expr.WriteString(class)
+			i += n - 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return filterRule{}, false // e.g. an invalid character class, which never matches in git either
+	}
+	rule.re = re
+	return rule, true
+}
+
+// compileFilterClass compiles the bracket expression at the start of pattern, e.g. "[!a-z]" or "[[:alpha:]_]",
+// into a character class of regexp, and returns the length of it. It's false if the bracket is not closed.
+// The class never matches "/", even if it's negated or "/" is in a range, as git does.
+func compileFilterClass(pattern string) (string, int, bool) {
+	var class strings.Builder
+	class.WriteString("[")
+	i := 1
+	negated := i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^')
+	if negated {
+		class.WriteString("^/")
+		i++
+	}
+
+	// next reads a member of the class, which can be escaped by a backslash
+	next := func() rune {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+		}
+		r, size := utf8.DecodeRuneInString(pattern[i:])
+		i += size
+		return r
+	}
+	for first := true; i < len(pattern); first = false {
+		if pattern[i] == ']' && !first {
+			class.WriteString("]")
+			return class.String(), i + 1, true
+		}
+		if strings.HasPrefix(pattern[i:], "[:") {
+			if end := strings.Index(pattern[i+2:], ":]"); end >= 0 {
+				class.WriteString(pattern[i : i+2+end+2]) // checked by regexp.Compile
+				i += 2 + end + 2
+				continue
+			}
+		}
+		lo := next()
+		hi := lo
+		if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+			i++
+			hi = next()
+		}
+		if !negated && lo <= '/' && '/' <= hi {
+			if lo < '/' {
+				fmt.Fprintf(&class, `\x{%x}-\x{%x}`, lo, '/'-1)
+			}
+			if hi > '/' {
+				fmt.Fprintf(&class, `\x{%x}-\x{%x}`, '/'+1, hi)
+			}
+			continue
+		}
+		fmt.Fprintf(&class, `\x{%x}-\x{%x}`, lo, hi)
+	}
+	return "", 0, false
+}
@@ -0,0 +1,137 @@
+package copy_go
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFilter_Excluded(t *testing.T) {
+	cases := []struct {
+		rules []string
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{[]string{"*.log"}, "a.log", false, true},
+		{[]string{"*.log"}, "dir/sub/a.log", false, true},
+		{[]string{"*.log"}, "a.log.txt", false, false},
+		{[]string{"*.log", "!keep.log"}, "dir/keep.log", false, false},
+		{[]string{"*.log", "!keep.log", "dir/*.log"}, "dir/keep.log", false, true},
+		{[]string{"/build"}, "build", true, true},
+		{[]string{"/build"}, "sub/build", true, false},
+		{[]string{"build/"}, "build", false, false},
+		{[]string{"build/"}, "sub/build", true, true},
+		{[]string{"doc/*.md"}, "doc/a.md", false, true},
+		{[]string{"doc/*.md"}, "doc/sub/a.md", false, false},
+		{[]string{"**/tmp"}, "a/b/tmp", true, true},
+		{[]string{"**/tmp"}, "tmp", true, true},
+		{[]string{"a/**/b"}, "a/b", false, true},
+		{[]string{"a/**/b"}, "a/x/y/b", false, true},
+		{[]string{"a/**"}, "a/x/y", false, true},
+		{[]string{"a/**"}, "a", true, false},
+		{[]string{"file?.[ch]"}, "file1.c", false, true},
+		{[]string{"file[!0-9].c"}, "file1.c", false, false},
+		{[]string{"file[!0-9].c"}, "filex.c", false, true},
+		{[]string{"a[!x]b"}, "a/b", false, false},
+		{[]string{"a[!x]b"}, "acb", false, true},
+		{[]string{"a[+-0]b"}, "a/b", false, false},
+		{[]string{"a[+-0]b"}, "a.b", false, true},
+		{[]string{"[[:alpha:]]x"}, "ax", false, true},
+		{[]string{"[[:alpha:]]x"}, "1x", false, false},
+		{[]string{"[![:digit:]_]x"}, "ax", false, true},
+		{[]string{"[![:digit:]_]x"}, "_x", false, false},
+		{[]string{"[]]x"}, "]x", false, true},
+		{[]string{`[\]]x`}, "]x", false, true},
+		{[]string{"[a"}, "[a", false, true},
+		{[]string{`\#hash`, `\!bang`}, "#hash", false, true},
+		{[]string{`\#hash`, `\!bang`}, "!bang", false, true},
+		{[]string{"# comment", ""}, "# comment", false, false},
+		{[]string{"trailing   "}, "trailing", false, true},
+	}
+	for _, c := range cases {
+		if got := NewFilter(c.rules...).Excluded(c.rel, c.isDir); got != c.want {
+			t.Errorf("%q: Excluded(%q, %v) = %v, want %v", c.rules, c.rel, c.isDir, got, c.want)
+		}
+	}
+
+	filter := NewFilter()
+	filter.Exclude("*")
+	filter.Include("*/", "*.go")
+	for rel, want := range map[string]bool{"main.go": false, "sub": false, "README.md": true} {
+		if got := filter.Excluded(rel, rel == "sub"); got != want {
+			t.Errorf("Excluded(%q) = %v, want %v", rel, got, want)
+		}
+	}
+}
+
+func TestCopy_filterIgnoreFiles(t *testing.T) {
+	src := t.TempDir()
+	for name, data := range map[string]string{
+		".gitignore":              "*.log\n/build/\n",
+		"a.log":                   "",
+		"a.txt":                   "",
+		"build/out":               "",
+		"sub/.gitignore":          "!keep.log\n*.tmp\n",
+		"sub/keep.log":            "",
+		"sub/drop.log":            "",
+		"sub/x.tmp":               "",
+		"sub/build/out":           "",
+		"other/x.tmp":             "",
+		"other/node_modules/a.js": "",
+	} {
+		name = filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter := NewFilter("node_modules/")
+	filter.IgnoreFiles(".gitignore")
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := Copy(src, dst, Options{Skip: filter.Skip(nil, src), NumOfWorkers: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]bool{
+		"a.log":              false,
+		"a.txt":              true,
+		"build":              false,
+		"sub/keep.log":       true,
+		"sub/drop.log":       false,
+		"sub/x.tmp":          false,
+		"sub/build/out":      true,
+		"other/x.tmp":        true,
+		"other/node_modules": false,
+		"sub/.gitignore":     true,
+	} {
+		_, err := os.Lstat(filepath.Join(dst, filepath.FromSlash(name)))
+		if got := err == nil; got != want {
+			t.Errorf("%s: copied = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestCopy_filterFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"root/.dockerignore": {Data: []byte("*.md\n")},
+		"root/README.md":     {Data: []byte("")},
+		"root/main.go":       {Data: []byte("")},
+	}
+	filter := NewFilter()
+	filter.IgnoreFiles(".dockerignore")
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := Copy("root", dst, Options{FS: fsys, Skip: filter.Skip(fsys, "root")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "README.md")); !os.IsNotExist(err) {
+		t.Errorf("README.md is copied: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "main.go")); err != nil {
+		t.Error(err)
+	}
+}
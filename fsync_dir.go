@@ -0,0 +1,15 @@
+//go:build !windows && !plan9 && !js && !wasip1
+
+package copy_go
+
+import "os"
+
+// fsyncDir makes the entries of the directory durable.
+func fsyncDir(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
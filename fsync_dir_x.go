@@ -0,0 +1,7 @@
+//go:build windows || plan9 || js || wasip1
+
+package copy_go
+
+func fsyncDir(name string) error {
+	return nil // directories can't be fsynced on this platform
+}
@@ -1,8 +1,5 @@
 module github.com/AyakuraYuki/copy-go
 
-go 1.24.0
+go 1.25.0
 
-require (
-	golang.org/x/sync v0.11.0
-	golang.org/x/sys v0.30.0
-)
+require golang.org/x/sys v0.30.0
@@ -0,0 +1,123 @@
+package copy_go
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// hardlinks remembers where the files having more than one hard link have been copied to,
+// so that the other links to them are created as hard links, see Options.PreserveHardlinks.
+type hardlinks struct {
+	mu    sync.Mutex
+	files map[inode]*hardlink
+	flags map[string]uint32 // immutable and append-only deferred by preserveFlags, by the first copies
+}
+
+type inode struct {
+	dev, ino uint64
+}
+
+// hardlink is the first copy of a file having hard links.
+// done is closed when it's completely copied, with err and created set.
+// created is false when nothing has been copied, e.g. the source has vanished.
+type hardlink struct {
+	dst     string
+	done    chan struct{}
+	err     error
+	created bool
+}
+
+func newHardlinks() *hardlinks {
+	return &hardlinks{files: map[inode]*hardlink{}, flags: map[string]uint32{}}
+}
+
+// deferFlags defers setting the inode flags of the first copy dst until finish,
+// because no more links to it can be made once it's immutable or append-only.
+func (h *hardlinks) deferFlags(dst string, flags uint32) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.flags[dst] = flags
+}
+
+// finish sets the inode flags deferred, after all the links are made. A nil *hardlinks does nothing.
+func (h *hardlinks) finish(opt Options) error {
+	if h == nil {
+		return nil
+	}
+	ffs, ok := opt.DestFS.(InodeFlagsFS)
+	if !ok {
+		return nil
+	}
+	for _, dst := range slices.Sorted(maps.Keys(h.flags)) {
+		if err := applyInodeFlags(ffs, dst, h.flags[dst], immutableFlags, opt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isHardlinked tells if the file has other hard links to be preserved.
+func isHardlinked(info os.FileInfo, opt Options) bool {
+	if !opt.PreserveHardlinks || opt.intent.hardlinks == nil {
+		return false
+	}
+	_, _, nlink, ok := getInode(info)
+	return ok && nlink > 1
+}
+
+// claim returns the first copy of the file, or records dst as the first copy and returns first = true.
+func (h *hardlinks) claim(info os.FileInfo, dst string) (link *hardlink, first bool) {
+	dev, ino, _, _ := getInode(info)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if link, ok := h.files[inode{dev, ino}]; ok {
+		return link, false
+	}
+	link = &hardlink{dst: dst, done: make(chan struct{})}
+	h.files[inode{dev, ino}] = link
+	return link, true
+}
+
+// finish marks the first copy as completely copied.
+func (l *hardlink) finish(err *error) {
+	l.err = *err
+	close(l.done)
+}
+
+// hcopy is for a file whose first copy has already been claimed,
+// with creating a new hard link to the first copy.
+// If the first copy has failed or created nothing, or the WritableFS doesn't support hard links,
+// the file is copied as it is.
+//
+// Waiting for the first copy never deadlocks, because the first copy
+// is always running on another goroutine, which doesn't wait for other jobs in the meantime,
+// see shouldCopyFileInChunks.
+func hcopy(src, dst string, info os.FileInfo, link *hardlink, opt Options) error {
+	<-link.done
+	if link.err != nil || !link.created {
+		opt.PreserveHardlinks = false
+		return fcopy(src, dst, info, opt)
+	}
+
+	if err := opt.DestFS.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+	if exists(opt.DestFS, dst) {
+		if err := opt.DestFS.Remove(dst); err != nil {
+			return err
+		}
+	}
+	if err := opt.DestFS.Link(link.dst, dst); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			opt.PreserveHardlinks = false
+			return fcopy(src, dst, info, opt) // e.g. ZipFS
+		}
+		return err
+	}
+	opt.intent.report.linked()
+	return nil
+}
@@ -0,0 +1,42 @@
+//go:build !windows && !plan9 && !js
+
+package copy_go
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopy_preserveHardlinks(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "a"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"b", "c"} {
+		if err := os.Link(filepath.Join(src, "a"), filepath.Join(src, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, workers := range []int64{0, 4} {
+		dst := filepath.Join(t.TempDir(), "dst")
+		result, err := CopyWithResult(src, dst, Options{PreserveHardlinks: true, NumOfWorkers: workers})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Files != 1 || result.Hardlinks != 2 {
+			t.Errorf("workers %d: Files = %d, Hardlinks = %d, want 1, 2", workers, result.Files, result.Hardlinks)
+		}
+		a, _ := os.Stat(filepath.Join(dst, "a"))
+		for _, name := range []string{"b", "c"} {
+			info, err := os.Stat(filepath.Join(dst, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !os.SameFile(a, info) {
+				t.Errorf("workers %d: %s is not a hard link to a", workers, name)
+			}
+		}
+	}
+}
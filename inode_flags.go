@@ -0,0 +1,63 @@
+package copy_go
+
+import (
+	"errors"
+	"os"
+)
+
+// The inode flags of chattr(1) on linux, see Options.PreserveFlags.
+const (
+	flagSync      uint32 = 0x00000008 // FS_SYNC_FL
+	flagImmutable uint32 = 0x00000010 // FS_IMMUTABLE_FL
+	flagAppend    uint32 = 0x00000020 // FS_APPEND_FL
+	flagNodump    uint32 = 0x00000040 // FS_NODUMP_FL
+	flagNoatime   uint32 = 0x00000080 // FS_NOATIME_FL
+	flagDirsync   uint32 = 0x00010000 // FS_DIRSYNC_FL
+
+	// preservedFlags can be set by anyone owning the file.
+	preservedFlags = flagSync | flagNodump | flagNoatime | flagDirsync
+	// immutableFlags need CAP_LINUX_IMMUTABLE, and make the file unchangeable even by Copy.
+	immutableFlags = flagImmutable | flagAppend
+)
+
+// preserveFlags copies the inode flags of src to dst, after everything else is written.
+// Immutable and append-only are copied only if Options.ImmutableFlags allows it,
+// and, for a file having hard links, only after the copy is finished, so that the links to it can be made.
+// The flags the destination filesystem doesn't support are reported in Result.Warnings.
+func preserveFlags(src, dst string, info os.FileInfo, opt Options) error {
+	ffs, ok := opt.DestFS.(InodeFlagsFS)
+	if !ok || opt.FS != nil || !(info.Mode().IsRegular() || info.IsDir()) {
+		return nil
+	}
+	flags, err := getInodeFlags(src)
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			return nil // no flags to lose
+		}
+		return err
+	}
+	mask := preservedFlags
+	if opt.ImmutableFlags {
+		if flags&immutableFlags != 0 && isHardlinked(info, opt) {
+			opt.intent.hardlinks.deferFlags(dst, flags&immutableFlags)
+		} else {
+			mask |= immutableFlags
+		}
+	}
+	return applyInodeFlags(ffs, dst, flags&mask, mask, opt)
+}
+
+// applyInodeFlags sets the flags in mask of dst to flags, if any of them is set.
+func applyInodeFlags(ffs InodeFlagsFS, dst string, flags, mask uint32, opt Options) error {
+	if flags == 0 {
+		return nil
+	}
+	if err := ffs.SetInodeFlags(dst, flags, mask); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			opt.intent.report.warn(err)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+//go:build linux
+
+package copy_go
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// getInodeFlags reads the inode flags of a file or a directory by FS_IOC_GETFLAGS.
+func getInodeFlags(name string) (uint32, error) {
+	f, err := os.OpenFile(name, os.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	flags, err := unix.IoctlGetUint32(int(f.Fd()), unix.FS_IOC_GETFLAGS)
+	return flags, inodeFlagsError("getflags", name, err)
+}
+
+// setInodeFlags sets the inode flags in mask to flags by FS_IOC_SETFLAGS, leaving the others as they are.
+func setInodeFlags(name string, flags, mask uint32) error {
+	f, err := os.OpenFile(name, os.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	current, err := unix.IoctlGetUint32(int(f.Fd()), unix.FS_IOC_GETFLAGS)
+	if err != nil {
+		return inodeFlagsError("getflags", name, err)
+	}
+	if current&mask == flags {
+		return nil
+	}
+	err = unix.IoctlSetPointerInt(int(f.Fd()), unix.FS_IOC_SETFLAGS, int(current&^mask|flags))
+	return inodeFlagsError("setflags", name, err)
+}
+
+// inodeFlagsError makes the errors of the filesystems not supporting the flags errors.ErrUnsupported.
+func inodeFlagsError(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, unix.ENOTTY) || errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.EINVAL) {
+		err = fmt.Errorf("%w: %w", errors.ErrUnsupported, err)
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
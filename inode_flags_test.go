@@ -0,0 +1,130 @@
+//go:build linux
+
+package copy_go
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCopy_preserveFlags(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(filepath.Join(src, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"nodump", "immutable"} {
+		if err := os.WriteFile(filepath.Join(src, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := setInodeFlags(filepath.Join(src, "nodump"), flagNodump, flagNodump); err != nil {
+		t.Skip("inode flags are not supported:", err)
+	}
+	if err := setInodeFlags(filepath.Join(src, "dir"), flagNoatime, flagNoatime); err != nil {
+		t.Fatal(err)
+	}
+	immutable := setInodeFlags(filepath.Join(src, "immutable"), flagImmutable, flagImmutable) == nil
+	t.Cleanup(func() {
+		_ = setInodeFlags(filepath.Join(src, "immutable"), 0, flagImmutable)
+	})
+
+	for _, allowed := range []bool{false, true} {
+		dst := filepath.Join(t.TempDir(), "dst")
+		result, err := CopyWithResult(src, dst, Options{PreserveFlags: true, ImmutableFlags: allowed})
+		t.Cleanup(func() {
+			_ = setInodeFlags(filepath.Join(dst, "immutable"), 0, flagImmutable)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Warnings) != 0 {
+			t.Errorf("Warnings = %v", result.Warnings)
+		}
+
+		want := map[string]uint32{"nodump": flagNodump, "dir": flagNoatime}
+		if immutable {
+			want["immutable"] = 0
+			if allowed {
+				want["immutable"] = flagImmutable
+			}
+		}
+		for name, want := range want {
+			flags, err := getInodeFlags(filepath.Join(dst, name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := flags & (preservedFlags | immutableFlags); got != want {
+				t.Errorf("allowed %v: %s: flags = %#x, want %#x", allowed, name, got, want)
+			}
+		}
+	}
+}
+
+// unsupportedFlagsFS is OSFS on a filesystem without inode flags.
+type unsupportedFlagsFS struct{ OSFS }
+
+func (unsupportedFlagsFS) SetInodeFlags(name string, flags, mask uint32) error {
+	return inodeFlagsError("setflags", name, syscall.ENOTTY)
+}
+
+func TestCopy_preserveFlagsUnsupported(t *testing.T) {
+	src := t.TempDir()
+	name := filepath.Join(src, "nodump")
+	if err := os.WriteFile(name, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := setInodeFlags(name, flagNodump, flagNodump); err != nil {
+		t.Skip("inode flags are not supported:", err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	result, err := CopyWithResult(src, dst, Options{PreserveFlags: true, DestFS: unsupportedFlagsFS{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Warnings) != 1 || !errors.Is(result.Warnings[0], errors.ErrUnsupported) {
+		t.Errorf("Warnings = %v, want one of errors.ErrUnsupported", result.Warnings)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "nodump")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCopy_preserveFlagsHardlinked(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "a"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(src, "a"), filepath.Join(src, "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := setInodeFlags(filepath.Join(src, "a"), flagImmutable, flagImmutable); err != nil {
+		t.Skip("immutable is not allowed:", err)
+	}
+	t.Cleanup(func() {
+		_ = setInodeFlags(filepath.Join(src, "a"), 0, flagImmutable)
+	})
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	t.Cleanup(func() {
+		_ = setInodeFlags(filepath.Join(dst, "a"), 0, flagImmutable)
+	})
+	err := Copy(src, dst, Options{PreserveFlags: true, ImmutableFlags: true, PreserveHardlinks: true, NumOfWorkers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := os.Stat(filepath.Join(dst, "a"))
+	b, _ := os.Stat(filepath.Join(dst, "b"))
+	if a == nil || b == nil || !os.SameFile(a, b) {
+		t.Errorf("a and b are not linked: %v, %v", a, b)
+	}
+	if flags, err := getInodeFlags(filepath.Join(dst, "b")); err != nil || flags&flagImmutable == 0 {
+		t.Errorf("flags = %#x, %v, want immutable", flags, err)
+	}
+}
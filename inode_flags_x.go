@@ -0,0 +1,16 @@
+//go:build !linux
+
+package copy_go
+
+import (
+	"errors"
+	"os"
+)
+
+func getInodeFlags(name string) (uint32, error) {
+	return 0, errors.ErrUnsupported
+}
+
+func setInodeFlags(name string, flags, mask uint32) error {
+	return &os.PathError{Op: "setflags", Path: name, Err: errors.ErrUnsupported}
+}
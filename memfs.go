@@ -0,0 +1,568 @@
+package copy_go
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errNotDir   = errors.New("not a directory")
+	errIsDir    = errors.New("is a directory")
+	errNotEmpty = errors.New("directory not empty")
+)
+
+// MemFS is an in-memory filesystem.
+// It's a WritableFS to copy into, and also an fs.FS to copy from,
+// which is handy to test filters and options without touching the disk.
+//
+// Names given as WritableFS are cleaned and rooted at the top of MemFS,
+// e.g. "dst/a", "/dst/a" and "./dst/a" are the same entry "dst/a" of fs.FS.
+type MemFS struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode
+}
+
+// memNode is an entry of MemFS. Hard links share the same memNode.
+type memNode struct {
+	mode   fs.FileMode
+	data   []byte
+	target string // of symlink
+	atime  time.Time
+	mtime  time.Time
+	ctime  time.Time
+	uid    int
+	gid    int
+	rdev   uint64
+}
+
+var _ interface {
+	LchownFS
+	LchtimesFS
+	MknodFS
+	fs.ReadDirFS
+	fs.ReadLinkFS
+	fs.StatFS
+} = (*MemFS)(nil)
+
+// NewMemFS returns an empty MemFS, which has only the root directory.
+func NewMemFS() *MemFS {
+	now := time.Now()
+	return &MemFS{
+		nodes: map[string]*memNode{
+			".": {mode: fs.ModeDir | 0755, atime: now, mtime: now, ctime: now},
+		},
+	}
+}
+
+// resolve follows the symlinks in name, and the last one only if followLast.
+// The name returned may not exist.
+func (m *MemFS) resolve(name string, followLast bool) (string, error) {
+	return resolveRooted(name, followLast, func(name string) (string, bool) {
+		n, ok := m.nodes[name]
+		if !ok || n.mode&fs.ModeSymlink == 0 {
+			return "", false
+		}
+		return n.target, true
+	})
+}
+
+// lookup returns the node of the resolved name.
+func (m *MemFS) lookup(op, name string, followLast bool) (string, *memNode, error) {
+	resolved, err := m.resolve(name, followLast)
+	if err != nil {
+		return "", nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	n, ok := m.nodes[resolved]
+	if !ok {
+		return "", nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return resolved, n, nil
+}
+
+// prepare resolves name for a new entry, which must not exist yet,
+// and whose parent must be an existing directory.
+func (m *MemFS) prepare(op, name string) (string, error) {
+	resolved, err := m.resolve(name, false)
+	if err != nil {
+		return "", &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if _, ok := m.nodes[resolved]; ok {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	}
+	if _, parent, err := m.lookup(op, path.Dir(resolved), true); err != nil {
+		return "", err
+	} else if !parent.mode.IsDir() {
+		return "", &fs.PathError{Op: op, Path: name, Err: errNotDir}
+	}
+	return resolved, nil
+}
+
+func (m *MemFS) add(name string, mode fs.FileMode) *memNode {
+	now := time.Now()
+	n := &memNode{mode: mode, atime: now, mtime: now, ctime: now}
+	m.nodes[name] = n
+	return n
+}
+
+func (m *MemFS) Create(name string) (WritableFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, err := m.resolve(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "create", Path: name, Err: err}
+	}
+	n, ok := m.nodes[resolved]
+	switch {
+	case !ok:
+		if resolved, err = m.prepare("create", resolved); err != nil {
+			return nil, err
+		}
+		n = m.add(resolved, 0666)
+	case n.mode.IsDir():
+		return nil, &fs.PathError{Op: "create", Path: name, Err: errIsDir}
+	default:
+		n.data = n.data[:0]
+		n.mtime = time.Now()
+	}
+	return &memFile{fs: m, node: n}, nil
+}
+
+func (m *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, err := m.prepare("mkdir", name)
+	if err != nil {
+		return err
+	}
+	m.add(resolved, fs.ModeDir|perm.Perm())
+	return nil
+}
+
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mkdirAll(name, perm)
+}
+
+func (m *MemFS) mkdirAll(name string, perm fs.FileMode) error {
+	resolved, err := m.resolve(name, true)
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if n, ok := m.nodes[resolved]; ok {
+		if !n.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: errNotDir}
+		}
+		return nil
+	}
+	if err := m.mkdirAll(path.Dir(resolved), perm); err != nil {
+		return err
+	}
+	m.add(resolved, fs.ModeDir|perm.Perm())
+	return nil
+}
+
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, n, err := m.lookup("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	n.mode = n.mode.Type() | mode&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)
+	n.ctime = time.Now()
+	return nil
+}
+
+func (m *MemFS) Chown(name string, uid, gid int) error {
+	return m.chown("chown", name, uid, gid, true)
+}
+
+func (m *MemFS) Lchown(name string, uid, gid int) error {
+	return m.chown("lchown", name, uid, gid, false)
+}
+
+func (m *MemFS) chown(op, name string, uid, gid int, followLast bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, n, err := m.lookup(op, name, followLast)
+	if err != nil {
+		return err
+	}
+	if uid != -1 {
+		n.uid = uid
+	}
+	if gid != -1 {
+		n.gid = gid
+	}
+	n.ctime = time.Now()
+	return nil
+}
+
+func (m *MemFS) Chtimes(name string, atime, mtime time.Time) error {
+	return m.chtimes("chtimes", name, atime, mtime, true)
+}
+
+func (m *MemFS) Lchtimes(name string, atime, mtime time.Time) error {
+	return m.chtimes("lchtimes", name, atime, mtime, false)
+}
+
+func (m *MemFS) chtimes(op, name string, atime, mtime time.Time, followLast bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, n, err := m.lookup(op, name, followLast)
+	if err != nil {
+		return err
+	}
+	if !atime.IsZero() {
+		n.atime = atime
+	}
+	if !mtime.IsZero() {
+		n.mtime = mtime
+	}
+	return nil
+}
+
+func (m *MemFS) Symlink(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, err := m.prepare("symlink", newname)
+	if err != nil {
+		return err
+	}
+	m.add(resolved, fs.ModeSymlink|fs.ModePerm).target = filepath.ToSlash(oldname)
+	return nil
+}
+
+func (m *MemFS) Link(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, n, err := m.lookup("link", oldname, false)
+	if err != nil {
+		return err
+	}
+	if n.mode.IsDir() {
+		return &fs.PathError{Op: "link", Path: oldname, Err: errIsDir}
+	}
+	resolved, err := m.prepare("link", newname)
+	if err != nil {
+		return err
+	}
+	m.nodes[resolved] = n
+	return nil
+}
+
+func (m *MemFS) Mkfifo(name string, perm fs.FileMode) error {
+	return m.Mknod(name, fs.ModeNamedPipe|perm.Perm(), 0)
+}
+
+func (m *MemFS) Mknod(name string, mode fs.FileMode, dev uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, err := m.prepare("mknod", name)
+	if err != nil {
+		return err
+	}
+	m.add(resolved, mode).rdev = dev
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, n, err := m.lookup("remove", name, false)
+	if err != nil {
+		return err
+	}
+	if n.mode.IsDir() && len(m.children(resolved)) > 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+	delete(m.nodes, resolved)
+	return nil
+}
+
+func (m *MemFS) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, err := m.resolve(name, false)
+	if err != nil {
+		return &fs.PathError{Op: "removeall", Path: name, Err: err}
+	}
+	if resolved == "." {
+		return &fs.PathError{Op: "removeall", Path: name, Err: fs.ErrInvalid}
+	}
+	for key := range m.nodes {
+		if key == resolved || strings.HasPrefix(key, resolved+"/") {
+			delete(m.nodes, key)
+		}
+	}
+	return nil
+}
+
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	from, n, err := m.lookup("rename", oldpath, false)
+	if err != nil {
+		return err
+	}
+	to, err := m.resolve(newpath, false)
+	if err != nil {
+		return &fs.PathError{Op: "rename", Path: newpath, Err: err}
+	}
+	if existing, ok := m.nodes[to]; ok {
+		if existing.mode.IsDir() != n.mode.IsDir() || len(m.children(to)) > 0 {
+			return &fs.PathError{Op: "rename", Path: newpath, Err: fs.ErrExist}
+		}
+	} else if to, err = m.prepare("rename", to); err != nil {
+		return err
+	}
+	if strings.HasPrefix(to, from+"/") {
+		return &fs.PathError{Op: "rename", Path: newpath, Err: fs.ErrInvalid}
+	}
+	moved := map[string]*memNode{to: n}
+	for key, node := range m.nodes {
+		if strings.HasPrefix(key, from+"/") {
+			moved[to+strings.TrimPrefix(key, from)] = node
+			delete(m.nodes, key)
+		}
+	}
+	delete(m.nodes, from)
+	maps.Copy(m.nodes, moved)
+	return nil
+}
+
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	return m.stat("lstat", name, false)
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	return m.stat("stat", name, true)
+}
+
+func (m *MemFS) stat(op, name string, followLast bool) (fs.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, n, err := m.lookup(op, name, followLast)
+	if err != nil {
+		return nil, err
+	}
+	return newMemInfo(path.Base(rootedName(name)), n), nil
+}
+
+func (m *MemFS) ReadLink(name string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, n, err := m.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return n.target, nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	resolved, n, err := m.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !n.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	names := m.children(resolved)
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, child := range names {
+		entries = append(entries, fs.FileInfoToDirEntry(newMemInfo(path.Base(child), m.nodes[child])))
+	}
+	return entries, nil
+}
+
+// children returns the sorted names of the direct children of dir.
+func (m *MemFS) children(dir string) []string {
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+	var names []string
+	for key := range m.nodes {
+		if key != "." && strings.HasPrefix(key, prefix) && !strings.Contains(key[len(prefix):], "/") {
+			names = append(names, key)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Open opens the named file for reading, as fs.FS.
+func (m *MemFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	info, err := m.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		entries, err := m.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &memDir{info: info, entries: entries}, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, n, err := m.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return &memOpenFile{info: info, Reader: bytes.NewReader(slices.Clone(n.data))}, nil
+}
+
+// memFile is a WritableFile of MemFS.
+type memFile struct {
+	fs     *MemFS
+	node   *memNode
+	offset int64
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	n, err := f.WriteAt(p, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if end := off + int64(len(p)); end > int64(len(f.node.data)) {
+		f.node.data = append(f.node.data, make([]byte, end-int64(len(f.node.data)))...)
+	}
+	copy(f.node.data[off:], p)
+	f.node.mtime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if size <= int64(len(f.node.data)) {
+		f.node.data = f.node.data[:size]
+	} else {
+		f.node.data = append(f.node.data, make([]byte, size-int64(len(f.node.data)))...)
+	}
+	return nil
+}
+
+func (f *memFile) Sync() error {
+	return nil
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+// memOpenFile is an fs.File of a regular file of MemFS.
+type memOpenFile struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *memOpenFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *memOpenFile) Close() error {
+	return nil
+}
+
+// memDir is an fs.File of a directory of MemFS.
+type memDir struct {
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *memDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *memDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errIsDir}
+}
+
+func (d *memDir) Close() error {
+	return nil
+}
+
+func (d *memDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if count > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > 0 && count < len(rest) {
+		rest = rest[:count]
+	}
+	d.offset += len(rest)
+	return rest, nil
+}
+
+// memInfo is a snapshot of a memNode as fs.FileInfo.
+// Its Sys() implements SysTimes, SysOwner and SysRdev.
+type memInfo struct {
+	name string
+	size int64
+	node memNode // without data
+}
+
+func newMemInfo(name string, n *memNode) *memInfo {
+	info := &memInfo{name: name, size: int64(len(n.data)), node: *n}
+	info.node.data = nil
+	return info
+}
+
+func (i *memInfo) Name() string       { return i.name }
+func (i *memInfo) Size() int64        { return i.size }
+func (i *memInfo) Mode() fs.FileMode  { return i.node.mode }
+func (i *memInfo) ModTime() time.Time { return i.node.mtime }
+func (i *memInfo) IsDir() bool        { return i.node.mode.IsDir() }
+func (i *memInfo) Sys() any           { return memSys{i.node} }
+
+// memSys is the Sys() of memInfo.
+type memSys struct {
+	node memNode
+}
+
+func (s memSys) AccessTime() time.Time { return s.node.atime }
+func (s memSys) ChangeTime() time.Time { return s.node.ctime }
+func (s memSys) Owner() (uid, gid int) { return s.node.uid, s.node.gid }
+func (s memSys) Rdev() uint64          { return s.node.rdev }
@@ -0,0 +1,70 @@
+package copy_go
+
+import (
+	"io/fs"
+	"time"
+)
+
+// SysTimes can be implemented by the value returned from fs.FileInfo.Sys(),
+// to provide the access time and the change time of an entry of Options.FS.
+// Without it, the modification time is used for all of them.
+type SysTimes interface {
+	AccessTime() time.Time
+	ChangeTime() time.Time
+}
+
+// SysOwner can be implemented by the value returned from fs.FileInfo.Sys(),
+// to provide the owner of an entry of Options.FS.
+// Without it, the owner is not preserved.
+type SysOwner interface {
+	Owner() (uid, gid int)
+}
+
+// getOwner returns the owner of the entry, if known.
+func getOwner(info fs.FileInfo) (uid, gid int, ok bool) {
+	if sys, ok := info.Sys().(SysOwner); ok {
+		uid, gid = sys.Owner()
+		return uid, gid, true
+	}
+	return statOwner(info)
+}
+
+// SysRdev can be implemented by the value returned from fs.FileInfo.Sys(),
+// to provide the device number of a device of Options.FS.
+// Without it, devices are not created.
+type SysRdev interface {
+	Rdev() uint64
+}
+
+// SysInode can be implemented by the value returned from fs.FileInfo.Sys(),
+// to tell which entries of Options.FS are hard links to the same file,
+// by the same device and inode number, and how many hard links the file has.
+// Without it, hard links are not preserved, see Options.PreserveHardlinks,
+// and Options.OneFileSystem is ignored.
+type SysInode interface {
+	Inode() (dev, ino, nlink uint64)
+}
+
+// SysXattrs can be implemented by the value returned from fs.FileInfo.Sys(),
+// to provide the extended attributes of an entry of Options.FS, see Options.PreserveXattrs.
+type SysXattrs interface {
+	Xattrs() map[string]string
+}
+
+// getRdev returns the device number of the device, if known.
+func getRdev(info fs.FileInfo) (uint64, bool) {
+	if sys, ok := info.Sys().(SysRdev); ok {
+		return sys.Rdev(), true
+	}
+	return statRdev(info)
+}
+
+// getInode returns the device and the inode number of the entry, if known,
+// and how many hard links it has.
+func getInode(info fs.FileInfo) (dev, ino, nlink uint64, ok bool) {
+	if sys, ok := info.Sys().(SysInode); ok {
+		dev, ino, nlink = sys.Inode()
+		return dev, ino, nlink, true
+	}
+	return statInode(info)
+}
@@ -0,0 +1,41 @@
+package copy_go
+
+import "io/fs"
+
+// setidBits are the bits which chown(2) clears, and which are dangerous under another owner.
+const setidBits = fs.ModeSetuid | fs.ModeSetgid
+
+// modeGuard is the WritableFS given to DestPermissionControl, or to the PermissionControlFunc of this package,
+// which holds back setuid and setgid from chmod of the destination,
+// so that they are set by release only after the owner is preserved.
+type modeGuard struct {
+	WritableFS
+	dst  string
+	held fs.FileMode // the mode chmod'ed with setuid or setgid
+}
+
+func newModeGuard(dfs WritableFS, dst string) *modeGuard {
+	return &modeGuard{WritableFS: dfs, dst: dst}
+}
+
+func (g *modeGuard) Chmod(name string, mode fs.FileMode) error {
+	if name != g.dst || mode&setidBits == 0 {
+		return g.WritableFS.Chmod(name, mode)
+	}
+	g.held = mode
+	return g.WritableFS.Chmod(name, mode&^setidBits)
+}
+
+// release sets setuid and setgid held back, if the owner has been preserved,
+// otherwise records them as dropped in Result.DroppedModes, as cp(1) does without -p.
+// The error is assigned to reported, respecting the error already reported.
+func (g *modeGuard) release(src string, ownerSet bool, opt Options, reported *error) {
+	if g.held&setidBits == 0 || *reported != nil {
+		return
+	}
+	if !ownerSet {
+		opt.intent.report.dropped(src, g.held&setidBits)
+		return
+	}
+	chmod(g.WritableFS, g.dst, g.held, reported)
+}
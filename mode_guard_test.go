@@ -0,0 +1,55 @@
+package copy_go
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestCopy_setidBits(t *testing.T) {
+	fsys := fstest.MapFS{
+		"root":        {Mode: fs.ModeDir | fs.ModeSetgid | 0755, Sys: ownerSys{1, 2}},
+		"root/suid":   {Mode: fs.ModeSetuid | 0755, Sys: ownerSys{1, 2}},
+		"root/sticky": {Mode: fs.ModeDir | fs.ModeSticky | 0777, Sys: ownerSys{1, 2}},
+	}
+
+	t.Run("owner not preserved", func(t *testing.T) {
+		mem := NewMemFS()
+		result, err := CopyWithResult("root", "out", Options{FS: fsys, DestFS: mem})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, want := range map[string]fs.FileMode{
+			"out":        fs.ModeDir | 0755,
+			"out/suid":   0755,
+			"out/sticky": fs.ModeDir | fs.ModeSticky | 0777,
+		} {
+			if info, err := mem.Lstat(name); err != nil || info.Mode() != want {
+				t.Errorf("%s: mode = %v, %v, want %v", name, info.Mode(), err, want)
+			}
+		}
+		want := map[string]fs.FileMode{"root": fs.ModeSetgid, "root/suid": fs.ModeSetuid}
+		if len(result.DroppedModes) != len(want) {
+			t.Errorf("DroppedModes = %v, want %v", result.DroppedModes, want)
+		}
+		for _, d := range result.DroppedModes {
+			if want[d.Src] != d.Bits {
+				t.Errorf("DroppedModes = %v, want %v", result.DroppedModes, want)
+			}
+		}
+	})
+
+	t.Run("owner mapped", func(t *testing.T) {
+		mem := NewMemFS()
+		result, err := CopyWithResult("root", "out", Options{FS: fsys, DestFS: mem, OwnerMapper: FixedOwner(5, 5)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info, err := mem.Lstat("out/suid"); err != nil || info.Mode() != fs.ModeSetuid|0755 {
+			t.Errorf("mode = %v, %v, want setuid kept", info.Mode(), err)
+		}
+		if len(result.DroppedModes) != 0 {
+			t.Errorf("DroppedModes = %v, want none", result.DroppedModes)
+		}
+	})
+}
@@ -1,32 +1,87 @@
 package copy_go
 
 import (
-	"context"
 	"io/fs"
 	"os"
-
-	"golang.org/x/sync/semaphore"
 )
 
 type Options struct {
 	// OnSymlink can specify what to do on symlink
 	OnSymlink func(src string) SymlinkAction
 
+	// OnSymlinkLoop can specify what to do on a symlink copied by Deep,
+	// which points to itself or to a directory containing it (default: LoopError).
+	OnSymlinkLoop func(src string) SymlinkLoopAction
+
+	// SymlinkTargets can specify how the targets of symlinks created by Shallow
+	// are written, when they point inside of the source tree (default: KeepTarget).
+	// e.g., RetargetRelative makes the copied tree relocatable.
+	SymlinkTargets SymlinkTargetAction
+
+	// OutOfTreeSymlink can specify what to do on symlinks to be created by Shallow,
+	// when they point outside of the source tree (default: KeepOutOfTree).
+	OutOfTreeSymlink OutOfTreeAction
+
+	// RewriteSymlink can rewrite the target of a symlink to be created by Shallow,
+	// after SymlinkTargets and OutOfTreeSymlink are applied.
+	RewriteSymlink func(src, dst, target string) (string, error)
+
+	// Contained makes sure that nothing escapes from the src root and the dst root,
+	// e.g. when copying a tree extracted from an untrusted archive:
+	//   - symlinks copied by Deep must point inside of the src root after resolving,
+	//   - symlinks created by Shallow must point inside of the src tree, as RejectOutOfTree does,
+	//     following the symlinks in the src tree on the way, e.g. "s/../.." with s -> "..",
+	//   - nothing is written through an existing symlink or outside of the dst root.
+	// Otherwise it fails with *SymlinkOutOfTreeError or *UnsafePathError.
+	// The dst tree is checked by Lstat just before writing, which doesn't stand against
+	// another process replacing a directory in it with a symlink at the same time.
+	// If dst can be written by others during the copy, give DestFS a BaseDirFS too,
+	// which never writes outside of its directory, whatever happens in it.
+	Contained bool
+
 	// OnDirExists can specify what to do when there's a directory already existing in destination
 	OnDirExists func(src, dst string) DirExistsAction
 
+	// OnDirExistsEntry is OnDirExists given the Entry, used instead of OnDirExists if given.
+	OnDirExistsEntry func(e *Entry) DirExistsAction
+
 	// OnError lets caller decide whether to continue on particular copy error
 	OnError func(src, dst string, err error) error
 
 	// Skip can specify which files should be skipped
 	Skip func(src, dst string, srcinfo os.FileInfo) (bool, error)
 
+	// SkipEntry is Skip given the Entry, used instead of Skip if given.
+	SkipEntry func(e *Entry) (bool, error)
+
 	// RenameDestination can specify the destination file or dir name if needed to rename
 	RenameDestination func(src, dst string) (string, error)
 
-	// Specials includes special files to be copied (default: false)
+	// RenameDestinationEntry is RenameDestination given the Entry, used instead of RenameDestination if given.
+	RenameDestinationEntry func(e *Entry) (string, error)
+
+	// MaxDepth limits how deep the directories are walked into, counting src as depth 0 (default: 0, no limit).
+	// e.g. 1 copies src and the entries in it, and the directories in it are created empty.
+	MaxDepth int
+
+	// OneFileSystem doesn't walk into the directories on other filesystems than src, as cp -x does,
+	// which are known by the device numbers of the entries (default: false).
+	// Ignored on windows and plan9, or if Options.FS doesn't tell them by SysInode.
+	OneFileSystem bool
+
+	// OnMountPoint can specify what to do on the directories OneFileSystem doesn't walk into (default: SkipMountPoint).
+	OnMountPoint MountPointAction
+
+	// Specials includes special files to be copied (default: false).
+	// Character and block devices are created with the same device numbers,
+	// which usually requires root privilege.
+	// Special files not copied are listed in Result.Skipped.
 	Specials bool
 
+	// OnSocket can specify what to do on unix domain sockets when Specials is true.
+	// A recreated socket is just a node in the filesystem, nobody is listening on it.
+	OnSocket func(src string) SocketAction
+
 	// AddPermission to every entity
 	// DO NOT MORE THAN 0777
 	// @OBSOLETE
@@ -38,36 +93,121 @@ type Options struct {
 	//
 	//		opt.PermissionControl = AddPermission(0222)
 	//
-	// see `permission.go` for more detail
+	// see `permission.go` for more detail, and ChmodPermission, SetPermission and UmaskPermission
 	PermissionControl PermissionControlFunc
 
+	// DestPermissionControl is PermissionControl making the changes through Options.DestFS,
+	// used instead of PermissionControl if given.
+	// Setuid and setgid are held back until the owner is preserved only from the chmod through the WritableFS given,
+	// which the PermissionControlFunc of this package do too, but the ones of others don't.
+	DestPermissionControl DestPermissionControlFunc
+
 	// Sync file after copy.
 	// Useful in case when file must be on the disk
 	// (in case crash happens, for example),
 	// at the expense of some performance penalty
 	Sync bool
 
-	// PreserveOwner preserve the uid and the gid of all entries
+	// Durability makes the copies survive a power loss once Copy returns (default: NotDurable),
+	// which Sync doesn't, because new entries are durable only after their directories are fsynced.
+	// FsyncEach fsyncs every file, and every directory after all the entries in it are written,
+	// including the parents of dst created for it. Directories are fsynced in batches by the workers.
+	// SyncFilesystem syncs the whole filesystem of dst by syncfs(2) once at the end instead,
+	// which is faster for many small files, but waits for the writes of other processes too.
+	// SyncFilesystem is FsyncEach except on linux, and directories are fsynced only on OSFS and not on windows.
+	Durability DurabilityAction
+
+	// Preallocate reserves the whole size of each file on the destination
+	// before copying its contents, to avoid fragmentation and
+	// to fail with a *PreallocateError before writing anything when there's no space left.
+	// Only works on linux, and silently does nothing on filesystems not supporting fallocate(2).
+	Preallocate bool
+
+	// CheckFreeSpace adds up the bytes and inodes to copy before copying anything,
+	// and fails with *InsufficientSpaceError if the destination filesystem can't hold them,
+	// instead of leaving a half-copied tree behind.
+	// Regular files are counted by their full length, sparse ones too, because their holes are written out.
+	// The tree is walked by Skip, RenameDestination, OnSymlink, OnSymlinkLoop and OnSocket,
+	// and the Entry versions of them, before copying, so they are called twice for each entry
+	// and must give the same answers both times.
+	// Only works on linux, darwin and freebsd.
+	CheckFreeSpace bool
+
+	// PreserveOwner preserve the uid and the gid of all entries,
+	// including symlinks themselves, named pipes, devices and sockets.
+	// Setuid and setgid are set only after the owner is, and are dropped without it,
+	// as cp(1) does, which are listed in Result.DroppedModes.
 	PreserveOwner bool
 
-	// PreserveTimes preserve the atime and the mtime of the entries.
-	// On linux we can preserve only up to 1 millisecond accuracy.
+	// OwnerMapper can map the owner of the entries to the owner of the copies,
+	// e.g. by MapOwnerRanges, MapOwnerByName or FixedOwner.
+	// If given, the owner of the entries is set even without PreserveOwner.
+	OwnerMapper OwnerMapper
+
+	// PreserveTimes preserve the atime and the mtime of the entries,
+	// including symlinks themselves, named pipes, devices and sockets,
+	// in nanoseconds as far as the filesystem can store them.
+	// The times of a directory are set after all the entries in it are copied.
 	PreserveTimes bool
 
+	// PreserveXattrs preserves the extended attributes of files and directories,
+	// read on linux, or from fs.FileInfo.Sys() implementing SysXattrs (default: false).
+	// Ignored if the source or the destination doesn't support them, see XattrFS.
+	PreserveXattrs bool
+
+	// PreserveHardlinks creates the files having hard links to the same file
+	// as hard links to the first copy of it, by WritableFS.Link,
+	// instead of copying the same contents again (default: false).
+	// Counted in Result.Hardlinks. Hard links of Options.FS are known by SysInode.
+	PreserveHardlinks bool
+
+	// PreserveFlags preserves the inode flags of files and directories set by chattr(1),
+	// e.g. nodump and noatime, read by FS_IOC_GETFLAGS on linux (default: false).
+	// They are set after everything else, and the ones not supported by the destination
+	// are reported in Result.Warnings. Ignored for Options.FS, see InodeFlagsFS.
+	PreserveFlags bool
+
+	// ImmutableFlags lets PreserveFlags preserve immutable and append-only too (default: false),
+	// which requires CAP_LINUX_IMMUTABLE, and makes the copies unchangeable even by the next Copy.
+	// With PreserveHardlinks, they are set to the files having hard links at the end of the copy.
+	ImmutableFlags bool
+
 	// The byte size of the buffer to use for copying files.
 	// Leave it to zero to use the default buffer size.
 	CopyBufferSize int
 
 	// If given, copy.Copy refers to this fs.FS instead of the OS filesystem.
 	// e.g., You can use embed.FS to copy files from embedded filesystem.
+	// src is then a path in this fs.FS, see fs.ValidPath.
+	// Times and owner of the entries are read from fs.FileInfo.Sys() if it's known,
+	// see SysTimes and SysOwner, otherwise the modification time is used for all the times.
 	FS fs.FS
 
+	// DestFS is the filesystem which copy.Copy writes into (default: OSFS).
+	// e.g., You can use MemFS to copy files into memory,
+	// or BaseDirFS to make sure that nothing is written outside of a directory.
+	// If it's not OSFS, dst is a path in this WritableFS, given as it is.
+	DestFS WritableFS
+
 	// NumOfWorkers represents the number of workers used for
 	// concurrent copying contents of directories.
+	// Workers take both listing directories and copying files from a shared queue,
+	// so the number of goroutines stays the same however large the tree is.
+	// The goroutine calling Copy counts as one of them.
 	// If 0 or 1, it does not use goroutine for copying directories.
-	// Please refer to https://pkg.go.dev/golang.org/x/sync/semaphore for more details.
 	NumOfWorkers int64
 
+	// ChunkThreshold is the file size in bytes above which a file is copied
+	// in chunks of ChunkSize by the workers in parallel,
+	// so that a single large file is not left to one goroutine.
+	// The chunks share the workers with other entries, so the concurrency stays at NumOfWorkers.
+	// If 0, or NumOfWorkers is 0 or 1, files are never chunked.
+	ChunkThreshold int64
+
+	// ChunkSize is the byte size of each chunk of a file copied in chunks.
+	// Leave it to zero to use the default chunk size (64 MiB).
+	ChunkSize int64
+
 	// PreferConcurrent is a function to determine whether
 	// to use goroutine for copying contents of directories.
 	// If PreferConcurrent is nil, which is default, it does concurrent
@@ -75,15 +215,26 @@ type Options struct {
 	// If NumOfWorkers is 0 or 1, this function will be ignored.
 	PreferConcurrent func(src, dst string) (bool, error)
 
+	// PreferConcurrentEntry is PreferConcurrent given the Entry, used instead of PreferConcurrent if given.
+	PreferConcurrentEntry func(e *Entry) (bool, error)
+
 	// internal use only
 	intent intent
 }
 
 type intent struct {
-	src string
-	dst string
-	ctx context.Context
-	sem *semaphore.Weighted
+	src       string
+	dst       string
+	rel       string // of the entry being copied, see Entry.Rel
+	rootDev   uint64 // device of src, only if OneFileSystem and known
+	oneFS     bool   // OneFileSystem is in effect
+	srcReal   string // src with symlinks resolved, only in Contained mode
+	pool      *workerPool
+	report    *report
+	ancestors *ancestor
+	hardlinks *hardlinks
+	syncer    *syncer
+	onSync    func(op, name string) // given to the syncer, only by tests
 }
 
 type SymlinkAction int
@@ -94,6 +245,51 @@ const (
 	Skip                         // Skip does nothing with symlink
 )
 
+type SymlinkLoopAction int
+
+const (
+	LoopError   SymlinkLoopAction = iota // LoopError fails with *SymlinkLoopError
+	LoopSkip                             // LoopSkip does nothing with the symlink
+	LoopShallow                          // LoopShallow creates new symlink as Shallow does, instead of going around
+)
+
+type SymlinkTargetAction int
+
+const (
+	KeepTarget       SymlinkTargetAction = iota // KeepTarget writes the same target as the src symlink
+	RetargetAbsolute                            // RetargetAbsolute points absolute targets inside of the tree to the dst tree
+	RetargetRelative                            // RetargetRelative points targets inside of the tree to the dst tree, with relative paths
+)
+
+type OutOfTreeAction int
+
+const (
+	KeepOutOfTree   OutOfTreeAction = iota // KeepOutOfTree writes the same target as the src symlink
+	RejectOutOfTree                        // RejectOutOfTree fails with *SymlinkOutOfTreeError, following the symlinks in the src tree on the way
+)
+
+type SocketAction int
+
+const (
+	RecreateSocket SocketAction = iota // RecreateSocket creates a new socket node at the dst
+	SkipSocket                         // SkipSocket does nothing with socket
+)
+
+type DurabilityAction int
+
+const (
+	NotDurable     DurabilityAction = iota // NotDurable leaves writing back to the OS
+	FsyncEach                              // FsyncEach fsyncs every file and directory
+	SyncFilesystem                         // SyncFilesystem syncs the filesystem of dst at the end
+)
+
+type MountPointAction int
+
+const (
+	SkipMountPoint  MountPointAction = iota // SkipMountPoint does nothing with the directory
+	EmptyMountPoint                         // EmptyMountPoint creates the directory without its contents
+)
+
 type DirExistsAction int
 
 const (
@@ -108,25 +304,62 @@ func getDefaultOptions(src, dst string) Options {
 		OnSymlink: func(string) SymlinkAction {
 			return Shallow // default: do shallow copy
 		},
-		OnDirExists:       nil,                // default: Merge
-		OnError:           nil,                // default: accept error
-		Skip:              nil,                // default: do NOT skip
-		RenameDestination: nil,                // default: no rename
-		Specials:          false,              // default: do NOT copy special files
-		AddPermission:     0,                  // default: add nothing
-		PermissionControl: PreservePermission, // default: just preserve permission
-		Sync:              false,              // default: do NOT sync
-		PreserveOwner:     false,              // default: do NOT preserve owner
-		PreserveTimes:     false,              // default: do NOT preserve the modification time
-		CopyBufferSize:    0,                  // default: use default buffer size
-		FS:                nil,                // default: do not specify file system
-		NumOfWorkers:      0,                  // default: copy in sequential
-		PreferConcurrent:  nil,                // default: no concurrent
+		OnSymlinkLoop: func(string) SymlinkLoopAction {
+			return LoopError // default: fail on symlink loop
+		},
+		SymlinkTargets:         KeepTarget,     // default: do NOT rewrite symlinks
+		OutOfTreeSymlink:       KeepOutOfTree,  // default: accept symlinks out of tree
+		RewriteSymlink:         nil,            // default: no rewrite
+		Contained:              false,          // default: do NOT check containment
+		OnDirExists:            nil,            // default: Merge
+		OnDirExistsEntry:       nil,            // default: OnDirExists
+		OnError:                nil,            // default: accept error
+		Skip:                   nil,            // default: do NOT skip
+		SkipEntry:              nil,            // default: Skip
+		RenameDestination:      nil,            // default: no rename
+		RenameDestinationEntry: nil,            // default: RenameDestination
+		MaxDepth:               0,              // default: no limit
+		OneFileSystem:          false,          // default: cross filesystems
+		OnMountPoint:           SkipMountPoint, // default: skip mount points if OneFileSystem
+		Specials:               false,          // default: do NOT copy special files
+		OnSocket: func(string) SocketAction {
+			return RecreateSocket // default: recreate sockets if Specials
+		},
+		AddPermission:         0,                  // default: add nothing
+		PermissionControl:     PreservePermission, // default: just preserve permission
+		DestPermissionControl: nil,                // default: PermissionControl
+		Sync:                  false,              // default: do NOT sync
+		Durability:            NotDurable,         // default: do NOT fsync directories
+		Preallocate:           false,              // default: do NOT preallocate
+		CheckFreeSpace:        false,              // default: do NOT check free space
+		PreserveOwner:         false,              // default: do NOT preserve owner
+		OwnerMapper:           nil,                // default: the same owner as the source
+		PreserveTimes:         false,              // default: do NOT preserve the modification time
+		PreserveXattrs:        false,              // default: do NOT preserve extended attributes
+		PreserveHardlinks:     false,              // default: do NOT preserve hard links
+		PreserveFlags:         false,              // default: do NOT preserve inode flags
+		ImmutableFlags:        false,              // default: do NOT set immutable and append-only
+		CopyBufferSize:        0,                  // default: use default buffer size
+		FS:                    nil,                // default: do not specify file system
+		DestFS:                OSFS{},             // default: write to the OS file system
+		NumOfWorkers:          0,                  // default: copy in sequential
+		ChunkThreshold:        0,                  // default: do NOT copy files in chunks
+		ChunkSize:             0,                  // default: use default chunk size
+		PreferConcurrent:      nil,                // default: no concurrent
+		PreferConcurrentEntry: nil,                // default: PreferConcurrent
 		intent: intent{
-			src: src,
-			dst: dst,
-			ctx: nil,
-			sem: nil,
+			src:       src,
+			dst:       dst,
+			rel:       ".",
+			rootDev:   0,
+			oneFS:     false,
+			srcReal:   "",
+			pool:      nil,
+			report:    nil,
+			ancestors: nil,
+			hardlinks: nil,
+			syncer:    nil,
+			onSync:    nil,
 		},
 	}
 }
@@ -141,9 +374,18 @@ func assureOptions(src, dst string, opts ...Options) Options {
 	if opts[0].OnSymlink == nil {
 		opts[0].OnSymlink = defaults.OnSymlink
 	}
+	if opts[0].OnSymlinkLoop == nil {
+		opts[0].OnSymlinkLoop = defaults.OnSymlinkLoop
+	}
+	if opts[0].OnSocket == nil {
+		opts[0].OnSocket = defaults.OnSocket
+	}
 	if opts[0].Skip == nil {
 		opts[0].Skip = defaults.Skip
 	}
+	if opts[0].DestFS == nil {
+		opts[0].DestFS = defaults.DestFS
+	}
 	if opts[0].AddPermission > 0 {
 		opts[0].PermissionControl = AddPermission(opts[0].AddPermission)
 	} else if opts[0].PermissionControl == nil {
@@ -151,15 +393,52 @@ func assureOptions(src, dst string, opts ...Options) Options {
 	}
 	opts[0].intent.src = defaults.intent.src
 	opts[0].intent.dst = defaults.intent.dst
+	opts[0].intent.rel = defaults.intent.rel
 	return opts[0]
 }
 
-func shouldCopyDirectoryConcurrent(src, dst string, opt Options) (bool, error) {
+func shouldCopyDirectoryConcurrent(src, dst string, info os.FileInfo, opt Options) (bool, error) {
 	if opt.NumOfWorkers <= 1 {
 		return false, nil
 	}
+	if opt.PreferConcurrentEntry != nil {
+		return opt.PreferConcurrentEntry(newEntry(src, dst, info, opt))
+	}
 	if opt.PreferConcurrent == nil {
 		return true, nil
 	}
 	return opt.PreferConcurrent(src, dst)
 }
+
+// shouldWalkInto tells if the directory should be copied, and if its contents should be too,
+// regarding MaxDepth and OneFileSystem.
+func shouldWalkInto(info os.FileInfo, opt Options) (copyDir, walkInto bool) {
+	if opt.intent.oneFS {
+		if dev, _, _, ok := getInode(info); ok && dev != opt.intent.rootDev {
+			return opt.OnMountPoint == EmptyMountPoint, false
+		}
+	}
+	if opt.MaxDepth > 0 && depthOf(opt.intent.rel) >= opt.MaxDepth {
+		return true, false
+	}
+	return true, true
+}
+
+// shouldCopyFileInChunks tells if the file should be copied in chunks by the workers.
+// A file having hard links is never chunked, so that hcopy can wait for it without deadlocking.
+func shouldCopyFileInChunks(info os.FileInfo, opt Options) bool {
+	if isHardlinked(info, opt) {
+		return false
+	}
+	return opt.intent.pool != nil && opt.ChunkThreshold > 0 && info.Size() > opt.ChunkThreshold
+}
+
+func shouldCopySpecial(src string, info os.FileInfo, opt Options) bool {
+	if !opt.Specials {
+		return false
+	}
+	if info.Mode()&os.ModeSocket != 0 {
+		return opt.OnSocket(src) == RecreateSocket
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package copy_go
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OwnerMapper maps the owner of a source entry to the owner of its copy, see Options.OwnerMapper.
+// uid and gid are -1 if the owner of the source is unknown,
+// and -1 returned leaves the uid or the gid as it is, as os.Chown does.
+type OwnerMapper func(uid, gid int) (newUid, newGid int, err error)
+
+// IDRange maps the IDs from From to From+Count-1 onto the IDs from To,
+// as a line of /proc/<pid>/uid_map does, e.g. IDRange{0, 100000, 65536}.
+type IDRange struct {
+	From  int
+	To    int
+	Count int
+}
+
+// MapOwnerRanges returns an OwnerMapper which maps the uid by uids and the gid by gids,
+// as user namespaces do. It fails with *UnmappedOwnerError if any ID is not in the ranges.
+// e.g., to copy a root filesystem for a rootless container:
+//
+//	ranges := []IDRange{{From: 0, To: 100000, Count: 65536}}
+//	opt.OwnerMapper = MapOwnerRanges(ranges, ranges)
+func MapOwnerRanges(uids, gids []IDRange) OwnerMapper {
+	return func(uid, gid int) (int, int, error) {
+		newUid, ok := mapID(uids, uid)
+		if !ok {
+			return 0, 0, &UnmappedOwnerError{Uid: uid, Gid: gid}
+		}
+		newGid, ok := mapID(gids, gid)
+		if !ok {
+			return 0, 0, &UnmappedOwnerError{Uid: uid, Gid: gid}
+		}
+		return newUid, newGid, nil
+	}
+}
+
+func mapID(ranges []IDRange, id int) (int, bool) {
+	if id == -1 {
+		return -1, true
+	}
+	for _, r := range ranges {
+		if r.From <= id && id < r.From+r.Count {
+			return r.To + id - r.From, true
+		}
+	}
+	return 0, false
+}
+
+// FixedOwner returns an OwnerMapper which gives everything to uid and gid.
+// -1 keeps the one of the source.
+func FixedOwner(uid, gid int) OwnerMapper {
+	return func(srcUid, srcGid int) (int, int, error) {
+		newUid, newGid := uid, gid
+		if newUid == -1 {
+			newUid = srcUid
+		}
+		if newGid == -1 {
+			newGid = srcGid
+		}
+		return newUid, newGid, nil
+	}
+}
+
+// MapOwnerByName returns an OwnerMapper which maps the owner by the names of the user and the group,
+// read from passwd and group, the files of the source system, e.g. "rootfs/etc/passwd" and "rootfs/etc/group",
+// to the IDs of the same names on this system, looked up by os/user.
+// The IDs not named in the files, or whose names are not on this system, are kept as they are,
+// as rsync does. An empty path keeps all the IDs of it.
+func MapOwnerByName(passwd, group string) (OwnerMapper, error) {
+	users, err := readIDNames(passwd)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := readIDNames(group)
+	if err != nil {
+		return nil, err
+	}
+
+	lookupUid := cachedLookup(func(name string) (string, error) {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", err
+		}
+		return u.Uid, nil
+	})
+	lookupGid := cachedLookup(func(name string) (string, error) {
+		g, err := user.LookupGroup(name)
+		if err != nil {
+			return "", err
+		}
+		return g.Gid, nil
+	})
+
+	return func(uid, gid int) (int, int, error) {
+		if name, ok := users[uid]; ok {
+			uid = lookupUid(name, uid)
+		}
+		if name, ok := groups[gid]; ok {
+			gid = lookupGid(name, gid)
+		}
+		return uid, gid, nil
+	}, nil
+}
+
+// readIDNames reads the names of the IDs from a file formatted as /etc/passwd or /etc/group,
+// whose first and third fields are the name and the ID.
+func readIDNames(name string) (map[int]string, error) {
+	names := map[int]string{}
+	if name == "" {
+		return names, nil
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 {
+			continue
+		}
+		id, err := strconv.Atoi(fields[2])
+		if err != nil {
+			continue
+		}
+		if _, ok := names[id]; !ok {
+			names[id] = fields[0] // the first one wins, as getpwuid(3) does
+		}
+	}
+	return names, nil
+}
+
+// cachedLookup returns a function looking up the ID of a name once,
+// which returns fallback if the name is not found on this system.
+func cachedLookup(lookup func(name string) (string, error)) func(name string, fallback int) int {
+	var mu sync.Mutex
+	ids := map[string]int{}
+	return func(name string, fallback int) int {
+		mu.Lock()
+		defer mu.Unlock()
+		id, ok := ids[name]
+		if !ok {
+			id = -1
+			if s, err := lookup(name); err == nil {
+				if n, err := strconv.Atoi(s); err == nil {
+					id = n
+				}
+			}
+			ids[name] = id
+		}
+		if id == -1 {
+			return fallback
+		}
+		return id
+	}
+}
@@ -0,0 +1,101 @@
+package copy_go
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+type ownerSys struct{ uid, gid int }
+
+func (s ownerSys) Owner() (uid, gid int) { return s.uid, s.gid }
+
+func TestCopy_ownerMapper(t *testing.T) {
+	fsys := fstest.MapFS{
+		"root":      {Mode: fs.ModeDir | 0755, Sys: ownerSys{0, 0}},
+		"root/file": {Data: []byte("hello"), Sys: ownerSys{1000, 100}},
+		"root/link": {Data: []byte("file"), Mode: fs.ModeSymlink, Sys: ownerSys{1, 2}},
+		"root/fifo": {Mode: fs.ModeNamedPipe | 0644, Sys: ownerSys{3, 4}},
+	}
+	ranges := []IDRange{{From: 0, To: 100000, Count: 65536}}
+
+	mem := NewMemFS()
+	err := Copy("root", "out", Options{FS: fsys, DestFS: mem, OwnerMapper: MapOwnerRanges(ranges, ranges)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string][2]int{
+		"out":      {100000, 100000},
+		"out/file": {101000, 100100},
+		"out/link": {100001, 100002},
+		"out/fifo": {100003, 100004},
+	} {
+		info, err := mem.Lstat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uid, gid, _ := getOwner(info); uid != want[0] || gid != want[1] {
+			t.Errorf("%s: owner = %d:%d, want %d:%d", name, uid, gid, want[0], want[1])
+		}
+	}
+
+	var unmapped *UnmappedOwnerError
+	small := []IDRange{{From: 0, To: 100000, Count: 1000}}
+	err = Copy("root", "out2", Options{FS: fsys, DestFS: NewMemFS(), OwnerMapper: MapOwnerRanges(small, ranges)})
+	if !errors.As(err, &unmapped) || unmapped.Uid != 1000 {
+		t.Errorf("Copy() = %v, want *UnmappedOwnerError of 1000", err)
+	}
+}
+
+func TestFixedOwner(t *testing.T) {
+	for _, c := range []struct {
+		uid, gid, srcUid, srcGid, wantUid, wantGid int
+	}{
+		{5, 6, 1, 2, 5, 6},
+		{5, -1, 1, 2, 5, 2},
+		{-1, 6, 1, 2, 1, 6},
+		{5, 6, -1, -1, 5, 6},
+	} {
+		mapper := FixedOwner(c.uid, c.gid)
+		for range 2 {
+			if uid, gid, err := mapper(c.srcUid, c.srcGid); err != nil || uid != c.wantUid || gid != c.wantGid {
+				t.Errorf("FixedOwner(%d, %d)(%d, %d) = %d, %d, %v, want %d, %d",
+					c.uid, c.gid, c.srcUid, c.srcGid, uid, gid, err, c.wantUid, c.wantGid)
+			}
+		}
+	}
+}
+
+func TestMapOwnerByName(t *testing.T) {
+	root, err := user.LookupId("0")
+	if err != nil || root.Username != "root" {
+		t.Skip("no root user on this system")
+	}
+	dir := t.TempDir()
+	passwd := filepath.Join(dir, "passwd")
+	group := filepath.Join(dir, "group")
+	if err := os.WriteFile(passwd, []byte("# comment\nroot:x:5000:5000::/root:/bin/sh\nnobody-here-xyz:x:5001:5001::/:/bin/false\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(group, []byte("nogroup-here-xyz:x:5000:\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mapper, err := MapOwnerByName(passwd, group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range [][4]int{{5000, 5000, 0, 5000}, {5001, 7, 5001, 7}, {42, 42, 42, 42}} {
+		if uid, gid, err := mapper(c[0], c[1]); err != nil || uid != c[2] || gid != c[3] {
+			t.Errorf("mapper(%d, %d) = %d, %d, %v, want %d, %d", c[0], c[1], uid, gid, err, c[2], c[3])
+		}
+	}
+
+	if _, err := MapOwnerByName(filepath.Join(dir, "missing"), ""); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("MapOwnerByName() = %v, want fs.ErrNotExist", err)
+	}
+}
@@ -9,38 +9,72 @@ import (
 // so that stuff can be copied recursively even if any original directory is NOT writable.
 const tmpDirectoryWritablePermission = os.FileMode(0755)
 
+// PermissionControlFunc makes the destination directory, if srcinfo is a directory,
+// and returns chmodfunc to be called after the destination is written.
+// It works on the OS filesystem, while the ones of this package work on Options.DestFS,
+// see DestPermissionControlFunc to write one working on Options.DestFS.
 type PermissionControlFunc func(srcinfo fs.FileInfo, dst string) (chmodfunc func(*error), err error)
 
+// DestPermissionControlFunc is PermissionControlFunc making all the changes through dfs, which is Options.DestFS,
+// see Options.DestPermissionControl.
+type DestPermissionControlFunc func(srcinfo fs.FileInfo, dst string, dfs WritableFS) (chmodfunc func(*error), err error)
+
 var (
 	AddPermission = func(perm os.FileMode) PermissionControlFunc {
-		return func(srcinfo fs.FileInfo, dst string) (func(*error), error) {
+		return destPermissionControl(func(srcinfo fs.FileInfo, dst string, dfs WritableFS) (func(*error), error) {
 			orig := srcinfo.Mode()
 			if srcinfo.IsDir() {
-				if err := os.MkdirAll(dst, tmpDirectoryWritablePermission); err != nil {
+				if err := dfs.MkdirAll(dst, tmpDirectoryWritablePermission); err != nil {
 					return func(*error) {}, err
 				}
 			}
 			return func(err *error) {
-				chmod(dst, orig|perm, err)
+				chmod(dfs, dst, orig|perm, err)
 			}, nil
-		}
+		})
 	}
-	PreservePermission                       = AddPermission(0)
-	DoNothing          PermissionControlFunc = func(srcinfo fs.FileInfo, dst string) (func(*error), error) {
+	PreservePermission = AddPermission(0)
+	DoNothing          = destPermissionControl(func(srcinfo fs.FileInfo, dst string, dfs WritableFS) (func(*error), error) {
 		if srcinfo.IsDir() {
-			if err := os.MkdirAll(dst, srcinfo.Mode()); err != nil {
+			if err := dfs.MkdirAll(dst, srcinfo.Mode()); err != nil {
 				return func(*error) {}, err
 			}
 		}
 		return func(e *error) {}, nil
-	}
+	})
 )
 
+// destInfo is srcinfo given to PermissionControl by Copy, which carries Options.DestFS
+// to the PermissionControlFunc of this package.
+type destInfo struct {
+	fs.FileInfo
+	dfs WritableFS
+}
+
+// destPermissionControl makes a PermissionControlFunc working on Options.DestFS of Copy,
+// and on the OS filesystem if it's called by others.
+func destPermissionControl(control DestPermissionControlFunc) PermissionControlFunc {
+	return func(srcinfo fs.FileInfo, dst string) (func(*error), error) {
+		if info, ok := srcinfo.(*destInfo); ok {
+			return control(info.FileInfo, dst, info.dfs)
+		}
+		return control(srcinfo, dst, OSFS{})
+	}
+}
+
+// controlPermission calls DestPermissionControl, or PermissionControl if not given.
+func controlPermission(srcinfo fs.FileInfo, dst string, dfs WritableFS, opt Options) (func(*error), error) {
+	if opt.DestPermissionControl != nil {
+		return opt.DestPermissionControl(srcinfo, dst, dfs)
+	}
+	return opt.PermissionControl(&destInfo{FileInfo: srcinfo, dfs: dfs}, dst)
+}
+
 // chmod ANYHOW changes file mode,
 // with assigning error raised during Chmod
 // BUT respecting the error already reported.
-func chmod(dir string, mode os.FileMode, reported *error) {
-	if err := os.Chmod(dir, mode); *reported == nil {
+func chmod(dfs WritableFS, dir string, mode os.FileMode, reported *error) {
+	if err := dfs.Chmod(dir, mode); *reported == nil {
 		*reported = err
 	}
 }
@@ -0,0 +1,199 @@
+package copy_go
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ChmodPermission returns a PermissionControlFunc which changes the permission of the copies
+// from the permission of the sources by expressions of chmod(1), e.g.,
+//
+//	opt.PermissionControl, err = ChmodPermission("u+rw,g-w,o=", "u+rwx,a+X")
+//
+// fileExpr is for files and dirExpr is for directories, where an empty one just preserves the permission.
+// An expression is either comma-separated symbolic clauses of [ugoa...][-+=][rwxXst...|ugo]...,
+// where no [ugoa] means "a", without the umask as chmod(1) would apply,
+// or an octal number, e.g. "0644", to set the permission outright.
+func ChmodPermission(fileExpr, dirExpr string) (PermissionControlFunc, error) {
+	fileMode, err := parseChmod(fileExpr)
+	if err != nil {
+		return nil, err
+	}
+	dirMode, err := parseChmod(dirExpr)
+	if err != nil {
+		return nil, err
+	}
+	return permissionControl(func(srcinfo fs.FileInfo) os.FileMode {
+		if srcinfo.IsDir() {
+			return dirMode(srcinfo.Mode(), true)
+		}
+		return fileMode(srcinfo.Mode(), false)
+	}), nil
+}
+
+// SetPermission returns a PermissionControlFunc which sets the permission of the copies outright,
+// file for files and dir for directories, whatever the permission of the sources is.
+// fs.ModeSetuid, fs.ModeSetgid and fs.ModeSticky can be given too.
+func SetPermission(file, dir os.FileMode) PermissionControlFunc {
+	return permissionControl(func(srcinfo fs.FileInfo) os.FileMode {
+		if srcinfo.IsDir() {
+			return dir & chmodBits
+		}
+		return file & chmodBits
+	})
+}
+
+// UmaskPermission is a PermissionControlFunc which masks the permission of the sources by the umask of the process,
+// as cp(1) does without -p. The umask is read once, when the package is initialized.
+var UmaskPermission = permissionControl(func(srcinfo fs.FileInfo) os.FileMode {
+	return srcinfo.Mode() & fs.ModePerm &^ getUmask()
+})
+
+// chmodBits are the bits which can be changed by chmod.
+const chmodBits = fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky
+
+// permissionControl makes a PermissionControlFunc which changes the permission of the copies to mode(srcinfo),
+// after writing them into the directories made writable as AddPermission does.
+func permissionControl(mode func(srcinfo fs.FileInfo) os.FileMode) PermissionControlFunc {
+	return destPermissionControl(func(srcinfo fs.FileInfo, dst string, dfs WritableFS) (func(*error), error) {
+		if srcinfo.IsDir() {
+			if err := dfs.MkdirAll(dst, tmpDirectoryWritablePermission); err != nil {
+				return func(*error) {}, err
+			}
+		}
+		return func(err *error) {
+			chmod(dfs, dst, mode(srcinfo), err)
+		}, nil
+	})
+}
+
+// chmodFunc changes a mode as an expression of chmod(1) does.
+type chmodFunc func(mode os.FileMode, isDir bool) os.FileMode
+
+// chmodClause is a clause of a symbolic expression, e.g. "ug+rw-x".
+type chmodClause struct {
+	who fs.FileMode // permission bits of the classes, e.g. 0770 for "ug"
+	ops []chmodOp
+}
+
+type chmodOp struct {
+	op    byte   // '+', '-' or '='
+	perms string // "rwxXst", or one of "ugo" to copy the permission of the class
+}
+
+func parseChmod(expr string) (chmodFunc, error) {
+	if expr == "" {
+		return func(mode os.FileMode, isDir bool) os.FileMode { return mode & chmodBits }, nil
+	}
+	if n, err := strconv.ParseUint(expr, 8, 32); err == nil {
+		if n > 07777 {
+			return nil, fmt.Errorf("invalid chmod expression %q", expr)
+		}
+		mode := fs.FileMode(n) & fs.ModePerm
+		for bit, m := range map[uint64]fs.FileMode{04000: fs.ModeSetuid, 02000: fs.ModeSetgid, 01000: fs.ModeSticky} {
+			if n&bit != 0 {
+				mode |= m
+			}
+		}
+		return func(os.FileMode, bool) os.FileMode { return mode }, nil
+	}
+
+	var clauses []chmodClause
+	for _, s := range strings.Split(expr, ",") {
+		clause, ok := parseChmodClause(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid chmod expression %q", expr)
+		}
+		clauses = append(clauses, clause)
+	}
+	return func(mode os.FileMode, isDir bool) os.FileMode {
+		mode &= chmodBits
+		for _, clause := range clauses {
+			mode = clause.apply(mode, isDir)
+		}
+		return mode
+	}, nil
+}
+
+func parseChmodClause(s string) (chmodClause, bool) {
+	var clause chmodClause
+	i := 0
+	for ; i < len(s) && strings.IndexByte("ugoa", s[i]) >= 0; i++ {
+		clause.who |= map[byte]fs.FileMode{'u': 0700, 'g': 0070, 'o': 0007, 'a': 0777}[s[i]]
+	}
+	if clause.who == 0 {
+		clause.who = 0777
+	}
+	for i < len(s) {
+		op := chmodOp{op: s[i]}
+		if strings.IndexByte("+-=", op.op) < 0 {
+			return chmodClause{}, false
+		}
+		i++
+		j := i
+		if j < len(s) && strings.IndexByte("ugo", s[j]) >= 0 {
+			j++
+		} else {
+			for j < len(s) && strings.IndexByte("rwxXst", s[j]) >= 0 {
+				j++
+			}
+		}
+		op.perms = s[i:j]
+		clause.ops = append(clause.ops, op)
+		i = j
+	}
+	return clause, len(clause.ops) > 0
+}
+
+func (c chmodClause) apply(mode os.FileMode, isDir bool) os.FileMode {
+	for _, op := range c.ops {
+		bits := c.bits(op.perms, mode, isDir)
+		switch op.op {
+		case '+':
+			mode |= bits
+		case '-':
+			mode &^= bits
+		case '=':
+			mode = mode&^c.bits("rwxst", mode, isDir) | bits
+		}
+	}
+	return mode
+}
+
+// bits returns the mode bits of perms for the classes of the clause.
+func (c chmodClause) bits(perms string, mode os.FileMode, isDir bool) os.FileMode {
+	var bits fs.FileMode
+	if shift, ok := map[string]uint{"u": 6, "g": 3, "o": 0}[perms]; ok {
+		class := (mode >> shift) & 07
+		return class * 0111 & c.who // e.g. "g=u" copies the bits of the user to the group
+	}
+	for _, p := range perms {
+		switch p {
+		case 'r':
+			bits |= 0444 & c.who
+		case 'w':
+			bits |= 0222 & c.who
+		case 'x':
+			bits |= 0111 & c.who
+		case 'X':
+			if isDir || mode&0111 != 0 {
+				bits |= 0111 & c.who
+			}
+		case 's':
+			if c.who&0700 != 0 {
+				bits |= fs.ModeSetuid
+			}
+			if c.who&0070 != 0 {
+				bits |= fs.ModeSetgid
+			}
+		case 't':
+			if c.who&0007 != 0 {
+				bits |= fs.ModeSticky
+			}
+		}
+	}
+	return bits
+}
@@ -0,0 +1,84 @@
+package copy_go
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestParseChmod(t *testing.T) {
+	cases := []struct {
+		expr  string
+		mode  fs.FileMode
+		isDir bool
+		want  fs.FileMode
+	}{
+		{"", 0644, false, 0644},
+		{"u+rwX,g-w,o=", 0664, false, 0640},
+		{"u+rwX,g-w,o=", 0775, true, 0755 &^ 0007},
+		{"a+X", 0644, false, 0644},
+		{"a+X", 0744, false, 0755},
+		{"a+X", 0600, true, 0711},
+		{"go=u-w", 0750, false, 0755},
+		{"+x", 0644, false, 0755},
+		{"u=rw,go=r", 0777, false, 0644},
+		{"u+s,g+s", 0755, false, 0755 | fs.ModeSetuid | fs.ModeSetgid},
+		{"o+t", 0777, true, 0777 | fs.ModeSticky},
+		{"a=", 0755 | fs.ModeSetuid, false, 0},
+		{"u-w+x", 0644, false, 0544},
+		{"0640", 0777, false, 0640},
+		{"4755", 0600, false, 0755 | fs.ModeSetuid},
+		{"750", 0, true, 0750},
+	}
+	for _, c := range cases {
+		f, err := parseChmod(c.expr)
+		if err != nil {
+			t.Errorf("parseChmod(%q) = %v", c.expr, err)
+			continue
+		}
+		if got := f(c.mode, c.isDir); got != c.want {
+			t.Errorf("%q on %v (dir: %v) = %v, want %v", c.expr, c.mode, c.isDir, got, c.want)
+		}
+	}
+
+	for _, expr := range []string{"u", "u+r,", "z+r", "u+q", "17777", "u+rw g-w"} {
+		if _, err := parseChmod(expr); err == nil {
+			t.Errorf("parseChmod(%q) succeeded", expr)
+		}
+	}
+}
+
+func TestCopy_permissionControls(t *testing.T) {
+	fsys := fstest.MapFS{
+		"root":          {Mode: fs.ModeDir | 0700},
+		"root/exec":     {Mode: 0700},
+		"root/file.txt": {Mode: 0666},
+	}
+	chmod, err := ChmodPermission("go=u-w,a+r", "a+rX,o-w")
+	if err != nil {
+		t.Fatal(err)
+	}
+	umask := getUmask()
+	for name, c := range map[string]struct {
+		control PermissionControlFunc
+		want    map[string]fs.FileMode
+	}{
+		"chmod": {chmod, map[string]fs.FileMode{"out": 0755, "out/exec": 0755, "out/file.txt": 0644}},
+		"set":   {SetPermission(0600, 0750), map[string]fs.FileMode{"out": 0750, "out/exec": 0600, "out/file.txt": 0600}},
+		"umask": {UmaskPermission, map[string]fs.FileMode{"out": 0700 &^ umask, "out/exec": 0700 &^ umask, "out/file.txt": 0666 &^ umask}},
+	} {
+		mem := NewMemFS()
+		if err := Copy("root", "out", Options{FS: fsys, DestFS: mem, PermissionControl: c.control}); err != nil {
+			t.Fatal(err)
+		}
+		for path, want := range c.want {
+			info, err := mem.Lstat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := info.Mode() & chmodBits; got != want {
+				t.Errorf("%s: %s mode = %v, want %v", name, path, got, want)
+			}
+		}
+	}
+}
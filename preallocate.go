@@ -0,0 +1,46 @@
+//go:build linux
+
+package copy_go
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocate reserves size bytes for f with fallocate(2).
+// Filesystems not supporting fallocate are silently skipped.
+func preallocate(f *os.File, size int64) error {
+	_, err := fallocate(f, size)
+	return err
+}
+
+// fallocate reserves size bytes for f with fallocate(2), which extends f to size,
+// and tells if the filesystem supports it.
+func fallocate(f *os.File, size int64) (bool, error) {
+	if size <= 0 {
+		return false, nil
+	}
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return false, err
+	}
+	var ferr error
+	if err = conn.Control(func(fd uintptr) {
+		for {
+			if ferr = unix.Fallocate(int(fd), 0, 0, size); ferr != unix.EINTR {
+				return
+			}
+		}
+	}); err != nil {
+		return false, err
+	}
+	if errors.Is(ferr, unix.EOPNOTSUPP) || errors.Is(ferr, unix.ENOSYS) {
+		return false, nil
+	}
+	if ferr != nil {
+		return false, &PreallocateError{Path: f.Name(), Size: size, Err: ferr}
+	}
+	return true, nil
+}
@@ -0,0 +1,91 @@
+//go:build linux
+
+package copy_go
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+)
+
+func TestCopy_preallocate(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(src, []byte("preallocated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "file")
+	if err := Copy(src, dst, Options{Preallocate: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(dst); string(got) != "preallocated" {
+		t.Errorf("copied content = %q", got)
+	}
+}
+
+func Test_preallocate_noSpace(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	err = preallocate(f, 1<<50)
+	if err == nil {
+		t.Skip("filesystem reserved 1 PiB or ignores fallocate")
+	}
+	var perr *PreallocateError
+	if !errors.As(err, &perr) || perr.Size != 1<<50 {
+		t.Errorf("preallocate() = %v, want *PreallocateError", err)
+	}
+	if info, _ := f.Stat(); info.Size() != 0 {
+		t.Errorf("size after failed preallocation = %d, want 0", info.Size())
+	}
+}
+
+// allocationChecker is a source which checks that the destination is allocated before any chunk is read.
+type allocationChecker struct {
+	io.ReaderAt
+	dst  *os.File
+	size int64
+	err  atomic.Pointer[error]
+}
+
+func (c *allocationChecker) ReadAt(p []byte, off int64) (int, error) {
+	info, err := c.dst.Stat()
+	if err == nil && info.Sys().(*syscall.Stat_t).Blocks*512 < c.size {
+		err = fmt.Errorf("%d bytes allocated before the chunks are written, want %d", info.Sys().(*syscall.Stat_t).Blocks*512, c.size)
+	}
+	if err != nil {
+		c.err.CompareAndSwap(nil, &err)
+	}
+	return c.ReaderAt.ReadAt(p, off)
+}
+
+func Test_fcopyChunks_allocated(t *testing.T) {
+	const size = 4 << 20
+	dst, err := os.Create(filepath.Join(t.TempDir(), "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	if ok, _ := fallocate(dst, 1); !ok {
+		t.Skip("filesystem doesn't support fallocate")
+	}
+
+	opt := assureOptions("src", "dst", Options{NumOfWorkers: 4, ChunkSize: 1 << 20})
+	opt.intent.pool = newWorkerPool(opt.NumOfWorkers)
+	defer opt.intent.pool.close()
+	src := &allocationChecker{ReaderAt: bytes.NewReader(bytes.Repeat([]byte("x"), size)), dst: dst, size: size}
+	if err := fcopyChunks(src, dst, size, opt); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.err.Load(); err != nil {
+		t.Error(*err)
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package copy_go
+
+import "os"
+
+func preallocate(f *os.File, size int64) error {
+	return nil // do nothing
+}
+
+func fallocate(f *os.File, size int64) (bool, error) {
+	return false, nil // do nothing
+}
@@ -0,0 +1,178 @@
+package copy_go
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"regexp"
+	"slices"
+	"time"
+)
+
+// Predicate tells if an entry matches some conditions,
+// which can be given to Options.Skip as it is, to skip the entries matching.
+// Predicates are combined by And, Or and Not. e.g., to copy only the files modified in the last 24 hours,
+// except the ones over 2 GiB:
+//
+//	opt.Skip = And(Not(OfType(fs.ModeDir)), Or(Not(ModifiedWithin(24*time.Hour)), LargerThan(2<<30)))
+//
+// Remember that a directory skipped is never walked into.
+type Predicate func(src, dst string, info os.FileInfo) (bool, error)
+
+// And matches when all the predicates match, evaluated from the first one until any doesn't.
+func And(predicates ...Predicate) Predicate {
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		for _, p := range predicates {
+			if ok, err := p(src, dst, info); err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+}
+
+// Or matches when any of the predicates matches, evaluated from the first one until any does.
+func Or(predicates ...Predicate) Predicate {
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		for _, p := range predicates {
+			if ok, err := p(src, dst, info); err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+}
+
+// Not matches when the predicate doesn't.
+func Not(p Predicate) Predicate {
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		ok, err := p(src, dst, info)
+		return !ok && err == nil, err
+	}
+}
+
+// LargerThan matches the entries whose size is over size bytes.
+func LargerThan(size int64) Predicate {
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		return info.Size() > size, nil
+	}
+}
+
+// SmallerThan matches the entries whose size is under size bytes.
+func SmallerThan(size int64) Predicate {
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		return info.Size() < size, nil
+	}
+}
+
+// SizeBetween matches the entries whose size is from least to most bytes, inclusive.
+func SizeBetween(least, most int64) Predicate {
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		return least <= info.Size() && info.Size() <= most, nil
+	}
+}
+
+// ModifiedBetween matches the entries modified from from until to, inclusive.
+// A zero time means no limit.
+func ModifiedBetween(from, to time.Time) Predicate {
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		return timeBetween(info.ModTime(), from, to), nil
+	}
+}
+
+// ModifiedWithin matches the entries modified in the duration d until the predicate is made.
+func ModifiedWithin(d time.Duration) Predicate {
+	return ModifiedBetween(time.Now().Add(-d), time.Time{})
+}
+
+// ChangedBetween matches the entries whose status changed from from until to, inclusive.
+// A zero time means no limit. The change time is the modification time if it's unknown.
+func ChangedBetween(from, to time.Time) Predicate {
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		return timeBetween(getTimeSpec(info).Ctime, from, to), nil
+	}
+}
+
+// ChangedWithin matches the entries whose status changed in the duration d until the predicate is made.
+func ChangedWithin(d time.Duration) Predicate {
+	return ChangedBetween(time.Now().Add(-d), time.Time{})
+}
+
+func timeBetween(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || !t.After(to))
+}
+
+// OfType matches the entries of any of the types, given as fs.FileMode.Type(),
+// e.g. OfType(0) for regular files, OfType(fs.ModeDir, fs.ModeSymlink) for directories and symlinks.
+func OfType(types ...fs.FileMode) Predicate {
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		return slices.Contains(types, info.Mode().Type()), nil
+	}
+}
+
+// OwnedBy matches the entries owned by the user. It never matches if the owner is unknown.
+func OwnedBy(uid int) Predicate {
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		owner, _, ok := getOwner(info)
+		return ok && owner == uid, nil
+	}
+}
+
+// OwnedByGroup matches the entries owned by the group. It never matches if the owner is unknown.
+func OwnedByGroup(gid int) Predicate {
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		_, group, ok := getOwner(info)
+		return ok && group == gid, nil
+	}
+}
+
+// PermAll matches the entries having all the permission bits, e.g. PermAll(0111) for executables by anyone.
+// fs.ModeSetuid, fs.ModeSetgid and fs.ModeSticky can be given too.
+func PermAll(bits fs.FileMode) Predicate {
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		return info.Mode()&bits == bits, nil
+	}
+}
+
+// PermAny matches the entries having any of the permission bits, e.g. PermAny(0002) for writable by others.
+func PermAny(bits fs.FileMode) Predicate {
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		return info.Mode()&bits != 0, nil
+	}
+}
+
+// NameMatches matches the entries whose base name matches the regular expression.
+func NameMatches(re *regexp.Regexp) Predicate {
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		return re.MatchString(info.Name()), nil
+	}
+}
+
+// CompareDest matches the entries whose destination already exists in dfs,
+// and compare returns true for. dfs is Options.DestFS, or the OS filesystem if nil.
+// The destination is the one before Options.RenameDestination.
+func CompareDest(dfs WritableFS, compare func(srcinfo, dstinfo os.FileInfo) bool) Predicate {
+	if dfs == nil {
+		dfs = OSFS{}
+	}
+	return func(src, dst string, info os.FileInfo) (bool, error) {
+		dstinfo, err := dfs.Lstat(dst)
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		return compare(info, dstinfo), nil
+	}
+}
+
+// DestUpToDate matches the files whose destination already exists,
+// with the same size and the modification time not older than the source,
+// which is handy to skip the files not updated since the last copy.
+func DestUpToDate(dfs WritableFS) Predicate {
+	return CompareDest(dfs, func(srcinfo, dstinfo os.FileInfo) bool {
+		return srcinfo.Mode().IsRegular() && dstinfo.Mode().IsRegular() &&
+			srcinfo.Size() == dstinfo.Size() && !dstinfo.ModTime().Before(srcinfo.ModTime())
+	})
+}
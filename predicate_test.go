@@ -0,0 +1,101 @@
+//go:build !windows && !plan9 && !js
+
+package copy_go
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestPredicates(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "data.bin")
+	if err := os.WriteFile(name, make([]byte, 100), 0750); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(name, old, old); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid, gid, _ := getOwner(info)
+
+	cases := map[string]struct {
+		p    Predicate
+		want bool
+	}{
+		"LargerThan":       {LargerThan(99), true},
+		"SmallerThan":      {SmallerThan(100), false},
+		"SizeBetween":      {SizeBetween(100, 200), true},
+		"ModifiedWithin":   {ModifiedWithin(24 * time.Hour), false},
+		"ModifiedBetween":  {ModifiedBetween(old.Add(-time.Hour), old.Add(time.Hour)), true},
+		"ChangedWithin":    {ChangedWithin(24 * time.Hour), true},
+		"OfType":           {OfType(0), true},
+		"OfType dir":       {OfType(fs.ModeDir, fs.ModeSymlink), false},
+		"OwnedBy":          {OwnedBy(uid), true},
+		"OwnedByGroup":     {OwnedByGroup(gid + 1), false},
+		"PermAll":          {PermAll(0110), true},
+		"PermAny":          {PermAny(0007), false},
+		"NameMatches":      {NameMatches(regexp.MustCompile(`\.bin$`)), true},
+		"And":              {And(OfType(0), LargerThan(1000)), false},
+		"Or":               {Or(OfType(fs.ModeDir), LargerThan(10)), true},
+		"Not":              {Not(LargerThan(10)), false},
+		"DestUpToDate":     {DestUpToDate(nil), true},
+		"CompareDest none": {CompareDest(nil, func(_, _ os.FileInfo) bool { return true }), false},
+	}
+	for label, c := range cases {
+		dst := name
+		if label == "CompareDest none" {
+			dst = filepath.Join(dir, "missing")
+		}
+		got, err := c.p(name, dst, info)
+		if err != nil {
+			t.Fatalf("%s: %v", label, err)
+		}
+		if got != c.want {
+			t.Errorf("%s = %v, want %v", label, got, c.want)
+		}
+	}
+}
+
+func TestCopy_predicateSkip(t *testing.T) {
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour)
+	for name, size := range map[string]int{"sub/new.txt": 10, "sub/old.txt": 10, "sub/large.txt": 1000} {
+		if err := os.WriteFile(filepath.Join(src, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chtimes(filepath.Join(src, "sub", "old.txt"), old, old); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"sub", "."} {
+		if err := os.Chtimes(filepath.Join(src, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	err := Copy(src, dst, Options{
+		Skip: And(Not(OfType(fs.ModeDir)), Or(Not(ModifiedWithin(24*time.Hour)), LargerThan(100))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{"sub/new.txt": true, "sub/old.txt": false, "sub/large.txt": false} {
+		_, err := os.Stat(filepath.Join(dst, name))
+		if got := err == nil; got != want {
+			t.Errorf("%s: copied = %v, want %v", name, got, want)
+		}
+	}
+}
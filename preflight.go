@@ -0,0 +1,152 @@
+package copy_go
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// spaceUsage is what copying a tree consumes on the destination filesystem.
+type spaceUsage struct {
+	bytes  uint64
+	inodes uint64
+}
+
+// preflight adds up the space needed to copy src to dst,
+// and fails with *InsufficientSpaceError if the filesystem of dst can't hold it.
+// It walks the tree the same way switchboard does, without writing anything,
+// calling the callbacks deciding what to copy once more than the copy does.
+func preflight(src, dst string, info os.FileInfo, opt Options) error {
+	if !isOSFS(opt.DestFS) {
+		return nil // unable to tell the space of other filesystems
+	}
+
+	var usage spaceUsage
+	if err := usage.measure(src, dst, info, opt); err != nil {
+		return err
+	}
+
+	availBytes, availInodes, ok, err := availableSpace(dst)
+	if err != nil || !ok {
+		return err // unable to tell on this platform, let it go
+	}
+	if usage.bytes > availBytes || (availInodes != unknownInodes && usage.inodes > availInodes) {
+		return &InsufficientSpaceError{
+			Path:            dst,
+			NeededBytes:     usage.bytes,
+			AvailableBytes:  availBytes,
+			NeededInodes:    usage.inodes,
+			AvailableInodes: availInodes,
+		}
+	}
+	return nil
+}
+
+func (u *spaceUsage) measure(src, dst string, info os.FileInfo, opt Options) (err error) {
+	if info.Mode()&(os.ModeDevice|os.ModeSocket) != 0 && !shouldCopySpecial(src, info, opt) {
+		return nil
+	}
+
+	if dst, err = renameDestination(src, dst, info, opt); err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		return u.measureSymlink(src, dst, info, opt)
+	case info.IsDir():
+		copyDir, walkInto := shouldWalkInto(info, opt)
+		if !copyDir {
+			return nil
+		}
+		u.inodes++
+		if !walkInto {
+			return nil
+		}
+		return u.measureDir(src, dst, info, opt)
+	case info.Mode().IsRegular():
+		u.inodes++
+		u.bytes += uint64(info.Size()) // holes are written out by fcopy, and Preallocate reserves the full length
+	default:
+		u.inodes++
+	}
+	return nil
+}
+
+func (u *spaceUsage) measureDir(srcdir, dstdir string, info os.FileInfo, opt Options) error {
+	opt.intent.ancestors = opt.intent.ancestors.push(srcdir, info)
+
+	var entries []fs.DirEntry
+	var err error
+	if opt.FS != nil {
+		entries, err = fs.ReadDir(opt.FS, srcdir)
+	} else {
+		entries, err = os.ReadDir(srcdir)
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		cs := filepath.Join(srcdir, info.Name())
+		cd := filepath.Join(dstdir, info.Name())
+		if err := u.measureNextOrSkip(cs, cd, info, opt.child(info.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *spaceUsage) measureSymlink(src, dst string, info os.FileInfo, opt Options) error {
+	if opt.FS != nil {
+		if _, ok := opt.FS.(fs.ReadLinkFS); !ok {
+			return nil
+		}
+	}
+
+	action := opt.OnSymlink(src)
+	if action == Deep {
+		orig, originfo, err := resolveSymlink(src, opt)
+		if err != nil {
+			return err
+		}
+		opt.intent.ancestors = opt.intent.ancestors.push(src, info)
+		if !opt.intent.ancestors.contains(orig, originfo) {
+			if opt.Contained && opt.FS == nil {
+				if err := assureContainedSymlink(src, orig, opt); err != nil {
+					return err
+				}
+			}
+			return u.measureNextOrSkip(orig, dst, originfo, opt)
+		}
+		switch opt.OnSymlinkLoop(src) {
+		case LoopShallow:
+			action = Shallow
+		case LoopSkip:
+			action = Skip
+		default:
+			return &SymlinkLoopError{Src: src, Target: orig}
+		}
+	}
+	if action == Shallow {
+		u.inodes++
+	}
+	return nil
+}
+
+// measureNextOrSkip is what copyNextOrSkip is to switchboard.
+func (u *spaceUsage) measureNextOrSkip(src, dst string, info os.FileInfo, opt Options) error {
+	if skip, err := shouldSkip(src, dst, info, opt); err != nil {
+		return err
+	} else if skip {
+		return nil
+	}
+	return u.measure(src, dst, info, opt)
+}
@@ -0,0 +1,87 @@
+package copy_go
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_spaceUsage_measure(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]int{
+		"a":          100,
+		"sub/b":      200,
+		"sub/c.skip": 400,
+	}
+	for name, size := range files {
+		if err := os.WriteFile(filepath.Join(src, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("a", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Name      string
+		OnSymlink SymlinkAction
+		Bytes     uint64
+		Inodes    uint64
+	}{
+		{"shallow", Shallow, 300, 5},
+		{"deep", Deep, 400, 5},
+		{"skip", Skip, 300, 4},
+	}
+	for _, tt := range tests {
+		opt := assureOptions(src, "dst", Options{
+			OnSymlink: func(string) SymlinkAction { return tt.OnSymlink },
+			Skip: func(src, dst string, info os.FileInfo) (bool, error) {
+				return strings.HasSuffix(src, ".skip"), nil
+			},
+		})
+		info, _ := os.Lstat(src)
+		var usage spaceUsage
+		if err := usage.measure(src, "dst", info, opt); err != nil {
+			t.Fatal(err)
+		}
+		if usage.bytes != tt.Bytes || usage.inodes != tt.Inodes {
+			t.Errorf("%s: measure() = %d bytes %d inodes, want %d bytes %d inodes",
+				tt.Name, usage.bytes, usage.inodes, tt.Bytes, tt.Inodes)
+		}
+	}
+}
+
+func Test_spaceUsage_measureSparse(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "sparse")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Truncate(1 << 30); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	info, _ := os.Lstat(src)
+	var usage spaceUsage
+	if err := usage.measure(src, "dst", info, assureOptions(src, "dst")); err != nil {
+		t.Fatal(err)
+	}
+	if usage.bytes != 1<<30 {
+		t.Errorf("measure() = %d bytes for a sparse file, want its full length, which is written out", usage.bytes)
+	}
+}
+
+func TestCopy_checkFreeSpace(t *testing.T) {
+	dst := filepath.Join(t.TempDir(), "not", "yet", "created")
+	if err := Copy("test/data/example", dst, Options{CheckFreeSpace: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dst, "README.md")); err != nil {
+		t.Error(err)
+	}
+}
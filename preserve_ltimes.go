@@ -1,16 +1,25 @@
-//go:build !windows && !plan9 && !js
+//go:build unix
 
 package copy_go
 
-import "golang.org/x/sys/unix"
+import (
+	"time"
 
-func preserveLtimes(src, dst string) (err error) {
-	info := new(unix.Stat_t)
-	if err = unix.Lstat(src, info); err != nil {
-		return err
+	"golang.org/x/sys/unix"
+)
+
+// lchtimes changes the times of the symlink itself in nanoseconds, by utimensat(2).
+// A zero time.Time leaves the time as it is, as os.Chtimes does.
+func lchtimes(name string, atime, mtime time.Time) error {
+	return unix.UtimesNanoAt(unix.AT_FDCWD, name, []unix.Timespec{
+		utimeSpec(atime),
+		utimeSpec(mtime),
+	}, unix.AT_SYMLINK_NOFOLLOW)
+}
+
+func utimeSpec(t time.Time) unix.Timespec {
+	if t.IsZero() {
+		return unix.Timespec{Sec: 0, Nsec: utimeOmit}
 	}
-	return unix.Lutimes(dst, []unix.Timeval{
-		unix.NsecToTimeval(info.Atim.Nano()),
-		unix.NsecToTimeval(info.Mtim.Nano()),
-	})
+	return unix.NsecToTimespec(t.UnixNano())
 }
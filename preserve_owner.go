@@ -1,23 +1,50 @@
-//go:build !windows && !plan9
-
 package copy_go
 
-import (
-	"io/fs"
-	"os"
-	"syscall"
-)
+import "io/fs"
+
+// shouldPreserveOwner tells if the owner of the copies should be set, by PreserveOwner or OwnerMapper.
+func shouldPreserveOwner(opt Options) bool {
+	return opt.PreserveOwner || opt.OwnerMapper != nil
+}
+
+// preserveOwner sets the owner of dst, and tells if it's set.
+func preserveOwner(dst string, info fs.FileInfo, opt Options) (bool, error) {
+	uid, gid, ok, err := mapOwner(info, opt)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := opt.DestFS.Chown(dst, uid, gid); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// preserveLowner preserves the owner of the symlink itself,
+// only if the WritableFS can do it.
+func preserveLowner(dst string, info fs.FileInfo, opt Options) error {
+	lchownfs, ok := opt.DestFS.(LchownFS)
+	if !ok {
+		return nil
+	}
+	uid, gid, ok, err := mapOwner(info, opt)
+	if err != nil || !ok {
+		return err
+	}
+	return lchownfs.Lchown(dst, uid, gid)
+}
 
-func preserveOwner(src, dst string, info fs.FileInfo) (err error) {
-	if info == nil {
-		if info, err = os.Stat(src); err != nil {
-			return err
-		}
+// mapOwner returns the owner of the copy of info, by OwnerMapper if given.
+// ok is false if there's nothing to change.
+func mapOwner(info fs.FileInfo, opt Options) (uid, gid int, ok bool, err error) {
+	uid, gid, ok = getOwner(info)
+	if opt.OwnerMapper == nil {
+		return uid, gid, ok, nil
+	}
+	if !ok {
+		uid, gid = -1, -1
 	}
-	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
-		if err = os.Chown(dst, int(stat.Uid), int(stat.Gid)); err != nil {
-			return err
-		}
+	if uid, gid, err = opt.OwnerMapper(uid, gid); err != nil {
+		return 0, 0, false, err
 	}
-	return nil
+	return uid, gid, uid != -1 || gid != -1, nil
 }
@@ -0,0 +1,130 @@
+//go:build linux
+
+package copy_go
+
+import (
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+const userNamespaceEnv = "COPY_GO_TEST_IN_USERNS"
+
+// inUserNamespace runs the test again as root in a new user namespace and returns false,
+// or returns true if it's already running there.
+// As many IDs as possible are mapped, so that the entries can be owned by others than root.
+func inUserNamespace(t *testing.T) bool {
+	if os.Getenv(userNamespaceEnv) == "1" {
+		return true
+	}
+	mappings := [][]syscall.SysProcIDMap{
+		{{ContainerID: 0, HostID: 0, Size: 65536}}, // only if we are root already
+		{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+	}
+	for _, uids := range mappings {
+		gids := uids
+		if uids[0].Size == 1 {
+			gids = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+		}
+		cmd := exec.Command(os.Args[0], "-test.run=^"+t.Name()+"$", "-test.v")
+		cmd.Env = append(os.Environ(), userNamespaceEnv+"=1")
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Cloneflags:  syscall.CLONE_NEWUSER,
+			UidMappings: uids,
+			GidMappings: gids,
+		}
+		out, err := cmd.CombinedOutput()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			t.Fatalf("in user namespace: %v\n%s", err, out)
+		}
+		if err == nil {
+			t.Logf("in user namespace:\n%s", out)
+			return false
+		}
+	}
+	t.Skip("user namespaces are not available")
+	return false
+}
+
+func TestCopy_preserveOwnerAndTimesOfEveryType(t *testing.T) {
+	if !inUserNamespace(t) {
+		return
+	}
+
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "file"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("file", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(filepath.Join(src, "fifo"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", filepath.Join(src, "sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// other IDs than 0 are available only if mapped
+	id := func(n int) int { return n }
+	if err := os.Lchown(filepath.Join(src, "file"), 1, 1); err != nil {
+		t.Log("only root is mapped:", err)
+		id = func(int) int { return 0 }
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	owners := map[string][2]int{"link": {id(1), id(2)}, "fifo": {id(3), id(4)}, "sock": {id(5), id(6)}}
+	for name, owner := range owners {
+		if err := os.Lchown(filepath.Join(src, name), owner[0], owner[1]); err != nil {
+			t.Fatal(err)
+		}
+		if err := lchtimes(filepath.Join(src, name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	suid := filepath.Join(src, "suid")
+	if err := os.WriteFile(suid, nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chown(suid, id(7), id(8)); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(suid, 0755|os.ModeSetuid|os.ModeSetgid); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	result, err := CopyWithResult(src, dst, Options{PreserveOwner: true, PreserveTimes: true, Specials: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "suid")); err != nil || info.Mode() != 0755|os.ModeSetuid|os.ModeSetgid {
+		t.Errorf("setuid file = %v, %v, want setuid and setgid kept", info, err)
+	}
+	if len(result.DroppedModes) != 0 {
+		t.Errorf("DroppedModes = %v, want none", result.DroppedModes)
+	}
+	for name, owner := range owners {
+		info, err := os.Lstat(filepath.Join(dst, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uid, gid, _ := statOwner(info); uid != owner[0] || gid != owner[1] {
+			t.Errorf("%s: owner = %d:%d, want %d:%d", name, uid, gid, owner[0], owner[1])
+		}
+		if !info.ModTime().Equal(mtime) {
+			t.Errorf("%s: mtime = %v, want %v", name, info.ModTime(), mtime)
+		}
+	}
+}
@@ -2,10 +2,21 @@ package copy_go
 
 import "os"
 
-func preserveTimes(dst string, info os.FileInfo) error {
+func preserveTimes(dst string, info os.FileInfo, dfs WritableFS) error {
 	spec := getTimeSpec(info)
-	if err := os.Chtimes(dst, spec.Atime, spec.Mtime); err != nil {
+	if err := dfs.Chtimes(dst, spec.Atime, spec.Mtime); err != nil {
 		return err
 	}
 	return nil
 }
+
+// preserveLtimes preserves the times of the symlink itself,
+// if the WritableFS is able to do so.
+func preserveLtimes(dst string, info os.FileInfo, dfs WritableFS) error {
+	lfs, ok := dfs.(LchtimesFS)
+	if !ok {
+		return nil
+	}
+	spec := getTimeSpec(info)
+	return lfs.Lchtimes(dst, spec.Atime, spec.Mtime)
+}
@@ -0,0 +1,76 @@
+//go:build !windows && !plan9 && !js
+
+package copy_go
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopy_preserveTimesInNanoseconds(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	makeTree(t, src, 3, 3)
+	if err := os.Symlink("file-0", filepath.Join(src, "dir-0", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	atime := mtime.Add(time.Hour + 987654321)
+	// from the leaves, so that setting the times of a child doesn't touch its parent
+	var paths []string
+	if err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		paths = append(paths, path)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for i := len(paths) - 1; i >= 0; i-- {
+		if err := lchtimes(paths[i], atime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, workers := range []int64{0, 4} {
+		dst := filepath.Join(t.TempDir(), "dst")
+		err := Copy(src, dst, Options{PreserveTimes: true, NumOfWorkers: workers, ChunkThreshold: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// atime is not checked, which reading the sources updates
+		err = filepath.Walk(dst, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.ModTime().Equal(mtime) {
+				t.Errorf("workers %d: %s: mtime = %v, want %v", workers, path, info.ModTime(), mtime)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_lchtimesZero(t *testing.T) {
+	link := filepath.Join(t.TempDir(), "link")
+	if err := os.Symlink("nowhere", link); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	if err := lchtimes(link, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := lchtimes(link, time.Time{}, time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime = %v, want it left as %v", info.ModTime(), mtime)
+	}
+}
@@ -0,0 +1,116 @@
+package copy_go
+
+import (
+	"io/fs"
+	"sync"
+)
+
+// Result reports what has been done by CopyWithResult.
+type Result struct {
+	Files        int64 // Files is the number of regular files copied
+	Dirs         int64 // Dirs is the number of directories copied
+	Symlinks     int64 // Symlinks is the number of symlinks created by Shallow
+	NamedPipes   int64 // NamedPipes is the number of named pipes created
+	CharDevices  int64 // CharDevices is the number of character devices created
+	BlockDevices int64 // BlockDevices is the number of block devices created
+	Sockets      int64 // Sockets is the number of unix domain sockets created
+	Hardlinks    int64 // Hardlinks is the number of hard links created by Options.PreserveHardlinks
+
+	// Skipped lists the special files which were found but not created,
+	// because of Options.Specials or Options.OnSocket,
+	// and the symlinks in Options.FS not implementing fs.ReadLinkFS.
+	Skipped []string
+
+	// DroppedModes lists the entries whose setuid or setgid bit is not copied,
+	// because the owner is not preserved, see Options.PreserveOwner.
+	DroppedModes []DroppedMode
+
+	// Warnings lists what couldn't be preserved but doesn't fail the copy,
+	// e.g. the inode flags not supported by the destination filesystem.
+	Warnings []error
+}
+
+// DroppedMode is an entry whose setuid or setgid bit is not copied.
+type DroppedMode struct {
+	Src  string
+	Bits fs.FileMode // fs.ModeSetuid and/or fs.ModeSetgid
+}
+
+// report collects Result from the workers.
+// A nil *report does nothing, so that internal functions can be called without it.
+type report struct {
+	mu     sync.Mutex
+	result Result
+}
+
+// created counts an entry of the given mode as created.
+func (r *report) created(mode fs.FileMode) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		r.result.Symlinks++
+	case mode.IsDir():
+		r.result.Dirs++
+	case mode&fs.ModeNamedPipe != 0:
+		r.result.NamedPipes++
+	case mode&fs.ModeSocket != 0:
+		r.result.Sockets++
+	case mode&fs.ModeCharDevice != 0:
+		r.result.CharDevices++
+	case mode&fs.ModeDevice != 0:
+		r.result.BlockDevices++
+	default:
+		r.result.Files++
+	}
+}
+
+// linked counts a hard link as created.
+func (r *report) linked() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result.Hardlinks++
+}
+
+// skipped records a special file which is not created.
+func (r *report) skipped(src string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result.Skipped = append(r.result.Skipped, src)
+}
+
+// dropped records the setuid or setgid bit not copied.
+func (r *report) dropped(src string, bits fs.FileMode) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result.DroppedModes = append(r.result.DroppedModes, DroppedMode{Src: src, Bits: bits})
+}
+
+// warn records an error which doesn't fail the copy.
+func (r *report) warn(err error) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result.Warnings = append(r.result.Warnings, err)
+}
+
+// snapshot returns the Result collected so far.
+func (r *report) snapshot() Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.result
+}
@@ -0,0 +1,61 @@
+package copy_go
+
+import (
+	"errors"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// maxLinkHops is how many symlinks are followed to resolve a name, same as linux.
+const maxLinkHops = 40
+
+var errTooManyLinks = errors.New("too many levels of symbolic links")
+
+// rootedName converts a name given to a WritableFS into a slash-separated path rooted at the top of it,
+// which is the key of MemFS.nodes.
+func rootedName(name string) string {
+	name = path.Clean("/" + filepath.ToSlash(name))[1:]
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// resolveRooted follows the symlinks in name, and the last one only if followLast,
+// where readlink returns the target of a rooted name if it's a symlink.
+// Absolute targets and ".." are resolved from the top, so the name never escapes it.
+// The name returned may not exist.
+func resolveRooted(name string, followLast bool, readlink func(name string) (string, bool)) (string, error) {
+	name = rootedName(name)
+	for hops := 0; ; hops++ {
+		resolved, followed := resolveRootedOnce(name, followLast, readlink)
+		if !followed {
+			return resolved, nil
+		}
+		if hops == maxLinkHops {
+			return "", errTooManyLinks
+		}
+		name = resolved
+	}
+}
+
+// resolveRootedOnce replaces the first symlink in name with its target.
+func resolveRootedOnce(name string, followLast bool, readlink func(name string) (string, bool)) (string, bool) {
+	if name == "." {
+		return name, false
+	}
+	elems := strings.Split(name, "/")
+	for i := range elems {
+		cur := strings.Join(elems[:i+1], "/")
+		target, ok := readlink(cur)
+		if !ok || (i == len(elems)-1 && !followLast) {
+			continue
+		}
+		if !path.IsAbs(target) {
+			target = path.Join(path.Dir(cur), target)
+		}
+		return rootedName(path.Join(append([]string{target}, elems[i+1:]...)...)), true
+	}
+	return name, false
+}
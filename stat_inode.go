@@ -0,0 +1,17 @@
+//go:build !windows && !plan9
+
+package copy_go
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// statInode returns the device and the inode number of the entry,
+// and how many hard links it has.
+func statInode(info fs.FileInfo) (dev, ino, nlink uint64, ok bool) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Dev), uint64(stat.Ino), uint64(stat.Nlink), true
+	}
+	return 0, 0, 0, false
+}
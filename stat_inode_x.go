@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package copy_go
+
+import "io/fs"
+
+func statInode(info fs.FileInfo) (dev, ino, nlink uint64, ok bool) {
+	return 0, 0, 0, false // hard links are not preserved
+}
@@ -0,0 +1,15 @@
+//go:build !windows && !plan9
+
+package copy_go
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func statOwner(info fs.FileInfo) (uid, gid int, ok bool) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int(stat.Uid), int(stat.Gid), true
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package copy_go
+
+import "io/fs"
+
+func statOwner(info fs.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false // owner is not preserved
+}
@@ -1,6 +1,7 @@
 package copy_go
 
 import (
+	"os"
 	"time"
 )
 
@@ -9,3 +10,24 @@ type timespec struct {
 	Atime time.Time // access time
 	Ctime time.Time // change time
 }
+
+// getTimeSpec returns the times of the entry,
+// falling back to the modification time for entries which don't tell the other times,
+// e.g. those of embed.FS or fstest.MapFS.
+func getTimeSpec(info os.FileInfo) timespec {
+	if sys, ok := info.Sys().(SysTimes); ok {
+		return timespec{
+			Mtime: info.ModTime(),
+			Atime: sys.AccessTime(),
+			Ctime: sys.ChangeTime(),
+		}
+	}
+	if spec, ok := statTimeSpec(info); ok {
+		return spec
+	}
+	return timespec{
+		Mtime: info.ModTime(),
+		Atime: info.ModTime(),
+		Ctime: info.ModTime(),
+	}
+}
@@ -6,11 +6,14 @@ import (
 	"time"
 )
 
-func getTimeSpec(info os.FileInfo) timespec {
-	stat := info.Sys().(*syscall.Stat_t)
+func statTimeSpec(info os.FileInfo) (timespec, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return timespec{}, false
+	}
 	return timespec{
 		Mtime: info.ModTime(),
 		Atime: time.Unix(stat.Atimespec.Sec, int64(stat.Atimespec.Nsec)),
 		Ctime: time.Unix(stat.Ctimespec.Sec, int64(stat.Ctimespec.Nsec)),
-	}
+	}, true
 }
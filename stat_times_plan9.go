@@ -6,10 +6,6 @@ import (
 
 // todo: check plan9 in the future
 
-func getTimeSpec(info os.FileInfo) timespec {
-	return timespec{
-		Mtime: info.ModTime(),
-		Atime: info.ModTime(),
-		Ctime: info.ModTime(),
-	}
+func statTimeSpec(info os.FileInfo) (timespec, bool) {
+	return timespec{}, false // fall back to the modification time
 }
@@ -6,11 +6,14 @@ import (
 	"time"
 )
 
-func getTimeSpec(info os.FileInfo) timespec {
-	stat := info.Sys().(*syscall.Stat_t)
+func statTimeSpec(info os.FileInfo) (timespec, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return timespec{}, false
+	}
 	return timespec{
 		Mtime: info.ModTime(),
 		Atime: time.Unix(0, int64(stat.Atime)),
 		Ctime: time.Unix(0, int64(stat.Ctime)),
-	}
+	}, true
 }
@@ -6,11 +6,14 @@ import (
 	"time"
 )
 
-func getTimeSpec(info os.FileInfo) timespec {
-	stat := info.Sys().(*syscall.Win32FileAttributeData)
+func statTimeSpec(info os.FileInfo) (timespec, bool) {
+	stat, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return timespec{}, false
+	}
 	return timespec{
 		Mtime: time.Unix(0, stat.LastWriteTime.Nanoseconds()),
 		Atime: time.Unix(0, stat.LastAccessTime.Nanoseconds()),
 		Ctime: time.Unix(0, stat.CreationTime.Nanoseconds()),
-	}
+	}, true
 }
@@ -0,0 +1,64 @@
+package copy_go
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// ancestor is a directory or a symlink on the current recursion path.
+// They are chained from the innermost to the root,
+// so that every branch of the recursion can push its own without locking.
+type ancestor struct {
+	name   string
+	info   os.FileInfo
+	parent *ancestor
+}
+
+func (a *ancestor) push(name string, info os.FileInfo) *ancestor {
+	return &ancestor{name: name, info: info, parent: a}
+}
+
+// contains tells if the entry is the same file (same dev and ino) as any of the ancestors.
+// Entries of fs.FS, which don't have dev and ino, are compared by their names.
+func (a *ancestor) contains(name string, info os.FileInfo) bool {
+	for ; a != nil; a = a.parent {
+		if a.name == name || os.SameFile(a.info, info) {
+			return true
+		}
+	}
+	return false
+}
+
+// rootAncestors chains the directories above src,
+// so that a Deep symlink pointing to one of them is detected as a loop at once.
+func rootAncestors(src string, opt Options) *ancestor {
+	var dirs []string
+	if opt.FS != nil {
+		for dir := path.Dir(src); dir != src; src, dir = dir, path.Dir(dir) {
+			dirs = append(dirs, dir)
+		}
+	} else {
+		for dir := filepath.Dir(src); dir != src; src, dir = dir, filepath.Dir(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	var a *ancestor
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if info, err := lstat(dirs[i], opt); err == nil {
+			a = a.push(dirs[i], info)
+		}
+	}
+	return a
+}
+
+// lstat returns the info of src, from Options.FS if given.
+// If the FS doesn't implement fs.ReadLinkFS, it's the same as fs.Stat.
+func lstat(src string, opt Options) (os.FileInfo, error) {
+	if opt.FS != nil {
+		return fs.Lstat(opt.FS, src)
+	}
+	return os.Lstat(src)
+}
@@ -0,0 +1,90 @@
+//go:build !windows && !plan9 && !js
+
+package copy_go
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopy_symlinkLoop(t *testing.T) {
+	deep := func(string) SymlinkAction { return Deep }
+
+	tests := []struct {
+		Name  string
+		Links map[string]string
+	}{
+		{"to ancestor", map[string]string{"a/b/up": ".."}},
+		{"to root", map[string]string{"a/root": "../"}},
+		{"to itself", map[string]string{"self": "self"}},
+		{"to each other", map[string]string{"x": "y", "y": "x"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			src := filepath.Join(t.TempDir(), "src")
+			if err := os.MkdirAll(filepath.Join(src, "a", "b"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, target := range tt.Links {
+				if err := os.Symlink(target, filepath.Join(src, name)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var loop *SymlinkLoopError
+			err := Copy(src, filepath.Join(t.TempDir(), "dst"), Options{OnSymlink: deep})
+			if !errors.As(err, &loop) {
+				t.Errorf("LoopError: Copy() = %v, want *SymlinkLoopError", err)
+			}
+
+			err = Copy(src, filepath.Join(t.TempDir(), "dst"), Options{
+				OnSymlink:     deep,
+				OnSymlinkLoop: func(string) SymlinkLoopAction { return LoopSkip },
+			})
+			if err != nil {
+				t.Errorf("LoopSkip: Copy() = %v", err)
+			}
+
+			dst := filepath.Join(t.TempDir(), "dst")
+			result, err := CopyWithResult(src, dst, Options{
+				OnSymlink:     deep,
+				OnSymlinkLoop: func(string) SymlinkLoopAction { return LoopShallow },
+			})
+			if err != nil {
+				t.Errorf("LoopShallow: Copy() = %v", err)
+			}
+			if result.Symlinks == 0 {
+				t.Errorf("LoopShallow: result = %+v, want symlinks", result)
+			}
+		})
+	}
+}
+
+func TestCopy_symlinkNotLoop(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(filepath.Join(src, "a", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "a", "b", "file"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// pointing to the same directory twice is not a loop
+	for _, name := range []string{"c", "d"} {
+		if err := os.Symlink(filepath.Join("a", "b"), filepath.Join(src, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	err := Copy(src, dst, Options{OnSymlink: func(string) SymlinkAction { return Deep }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"c", "d"} {
+		if _, err := os.Stat(filepath.Join(dst, name, "file")); err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package copy_go
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// symlinkTarget decides the target of the new symlink at dst replicating src,
+// whose original target is orig, regarding SymlinkTargets, OutOfTreeSymlink and RewriteSymlink.
+func symlinkTarget(src, dst, orig string, opt Options) (target string, err error) {
+	target = orig
+
+	resolved := orig
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(filepath.Dir(src), resolved)
+	}
+	rel, ok := relativeInTree(opt.intent.src, resolved)
+	if ok && (opt.OutOfTreeSymlink == RejectOutOfTree || opt.Contained) {
+		// filepath.Join collapses "s/.." even if s is a symlink, which the kernel follows first
+		ok = staysInTree(filepath.Dir(src), orig, opt)
+	}
+	if ok {
+		switch {
+		case opt.SymlinkTargets == RetargetAbsolute && filepath.IsAbs(orig):
+			target = filepath.Join(opt.intent.dst, rel)
+		case opt.SymlinkTargets == RetargetRelative:
+			if target, err = filepath.Rel(filepath.Dir(dst), filepath.Join(opt.intent.dst, rel)); err != nil {
+				return "", err
+			}
+		}
+	} else if opt.OutOfTreeSymlink == RejectOutOfTree || opt.Contained {
+		return "", &SymlinkOutOfTreeError{Src: src, Target: orig}
+	}
+
+	if opt.RewriteSymlink != nil {
+		return opt.RewriteSymlink(src, dst, target)
+	}
+	return target, nil
+}
+
+// relativeInTree returns the path of name relative to root,
+// only if name is root itself or under root, without resolving any symlinks.
+func relativeInTree(root, name string) (string, bool) {
+	rel, err := filepath.Rel(root, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// staysInTree resolves orig, the target of a symlink in dir, following the symlinks in the src tree
+// component by component as the kernel does, and tells if it never goes above the src root on the way.
+// Too many symlinks to follow are taken as out of the tree.
+func staysInTree(dir, orig string, opt Options) bool {
+	start, ok := relativeInTree(opt.intent.src, dir)
+	if !ok {
+		return true // the root itself is a symlink, nothing to resolve from
+	}
+	cur := splitSlash(start)
+	pending := splitSlash(orig)
+	if isAbs(orig, opt) {
+		if pending, ok = trimSrcRoot(orig, opt); !ok {
+			return false
+		}
+		cur = nil
+	}
+
+	for hops := 0; len(pending) > 0; {
+		elem := pending[0]
+		pending = pending[1:]
+		switch elem {
+		case "", ".":
+			continue
+		case "..":
+			if len(cur) == 0 {
+				return false
+			}
+			cur = cur[:len(cur)-1]
+			continue
+		}
+
+		name := joinSrc(opt, append(cur[:len(cur):len(cur)], elem))
+		info, err := lstat(name, opt)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			cur = append(cur, elem)
+			continue
+		}
+		if hops++; hops > maxLinkHops {
+			return false
+		}
+		target, err := readlink(name, opt)
+		if err != nil {
+			return false
+		}
+		next := splitSlash(target)
+		if isAbs(target, opt) {
+			if next, ok = trimSrcRoot(target, opt); !ok {
+				return false
+			}
+			cur = nil
+		}
+		pending = append(next, pending...)
+	}
+	return true
+}
+
+// trimSrcRoot returns the path components of an absolute name below the src root, without cleaning them.
+func trimSrcRoot(name string, opt Options) ([]string, bool) {
+	if opt.FS != nil {
+		return nil, false // fs.FS has no absolute paths
+	}
+	for _, root := range []string{opt.intent.src, opt.intent.srcReal} {
+		if root == "" {
+			continue
+		}
+		if name == root {
+			return nil, true
+		}
+		if rest, ok := strings.CutPrefix(name, strings.TrimSuffix(root, string(filepath.Separator))+string(filepath.Separator)); ok {
+			return splitSlash(rest), true
+		}
+	}
+	return nil, false
+}
+
+func isAbs(name string, opt Options) bool {
+	if opt.FS != nil {
+		return path.IsAbs(name)
+	}
+	return filepath.IsAbs(name)
+}
+
+// joinSrc joins the path components below the src root.
+func joinSrc(opt Options, elems []string) string {
+	if opt.FS != nil {
+		return path.Join(append([]string{opt.intent.src}, elems...)...)
+	}
+	return filepath.Join(append([]string{opt.intent.src}, elems...)...)
+}
+
+func splitSlash(name string) []string {
+	if name == "." {
+		return nil
+	}
+	return strings.Split(filepath.ToSlash(name), "/")
+}
@@ -0,0 +1,82 @@
+//go:build !windows && !plan9 && !js
+
+package copy_go
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_symlinkTarget(t *testing.T) {
+	tests := []struct {
+		Src       string
+		Orig      string
+		Targets   SymlinkTargetAction
+		OutOfTree OutOfTreeAction
+		Want      string
+		WantErr   bool
+	}{
+		{"/src/a/link", "/src/b/file", KeepTarget, KeepOutOfTree, "/src/b/file", false},
+		{"/src/a/link", "/src/b/file", RetargetAbsolute, KeepOutOfTree, "/dst/b/file", false},
+		{"/src/a/link", "/src/b/file", RetargetRelative, KeepOutOfTree, "../b/file", false},
+		{"/src/a/link", "../b/file", RetargetAbsolute, KeepOutOfTree, "../b/file", false},
+		{"/src/a/link", "../b/file", RetargetRelative, KeepOutOfTree, "../b/file", false},
+		{"/src/a/link", "/src", RetargetRelative, KeepOutOfTree, "..", false},
+		{"/src/a/link", "/etc/passwd", RetargetAbsolute, KeepOutOfTree, "/etc/passwd", false},
+		{"/src/a/link", "/etc/passwd", RetargetRelative, RejectOutOfTree, "", true},
+		{"/src/a/link", "../../etc/passwd", KeepTarget, RejectOutOfTree, "", true},
+		{"/src/a/link", "/srcfoo/file", KeepTarget, RejectOutOfTree, "", true},
+	}
+	for _, tt := range tests {
+		opt := assureOptions("/src", "/dst", Options{
+			SymlinkTargets:   tt.Targets,
+			OutOfTreeSymlink: tt.OutOfTree,
+		})
+		dst := filepath.Join("/dst", tt.Src[len("/src"):])
+		got, err := symlinkTarget(tt.Src, dst, tt.Orig, opt)
+		if (err != nil) != tt.WantErr || got != tt.Want {
+			t.Errorf("symlinkTarget(%q -> %q) = %q, %v, want %q", tt.Src, tt.Orig, got, err, tt.Want)
+		}
+	}
+}
+
+func TestCopy_rewriteSymlink(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	if err := os.MkdirAll(filepath.Join(src, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(src, "lib"), filepath.Join(src, "bin", "lib")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/etc/hosts", filepath.Join(src, "hosts")); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	err := Copy(src, dst, Options{
+		SymlinkTargets: RetargetRelative,
+		RewriteSymlink: func(src, dst, target string) (string, error) {
+			if target == "/etc/hosts" {
+				return "/etc/hostname", nil
+			}
+			return target, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.Readlink(filepath.Join(dst, "bin", "lib")); got != "../lib" {
+		t.Errorf("in-tree target = %q, want %q", got, "../lib")
+	}
+	if got, _ := os.Readlink(filepath.Join(dst, "hosts")); got != "/etc/hostname" {
+		t.Errorf("rewritten target = %q, want %q", got, "/etc/hostname")
+	}
+
+	var outOfTree *SymlinkOutOfTreeError
+	err = Copy(src, filepath.Join(t.TempDir(), "dst"), Options{OutOfTreeSymlink: RejectOutOfTree})
+	if !errors.As(err, &outOfTree) {
+		t.Errorf("Copy() = %v, want *SymlinkOutOfTreeError", err)
+	}
+}
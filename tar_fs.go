@@ -0,0 +1,149 @@
+package copy_go
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+)
+
+// tarXattrPrefix is the prefix of PAX records of extended attributes.
+const tarXattrPrefix = "SCHILY.xattr."
+
+// Gzip compresses an archive by gzip with the default compression level.
+var Gzip Compressor = func(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+
+// TarFS is a WritableFS which writes the copied tree into a tar archive, see NewTarFS.
+// e.g.,
+//
+//	tfs, err := NewTarFS(w, Gzip)
+//	err = Copy("your/directory", "directory", Options{DestFS: tfs, PreserveTimes: true})
+//	err = tfs.Close()
+//
+// Names are cleaned and rooted at the top of the archive, as MemFS does.
+//
+// A regular file is written into the archive as soon as it's closed,
+// which is after its mode, owner and times are set by Copy.
+// Directories, symlinks, named pipes and devices are written when TarFS is closed,
+// because their metadata is set after their contents.
+// Hard links are written as link entries, see Options.PreserveHardlinks,
+// and extended attributes as PAX records, see Options.PreserveXattrs.
+// Sockets can't be stored in a tar archive, and are reported as skipped.
+//
+// An entry already written can't be changed or removed any more,
+// e.g. Replace of Options.OnDirExists fails for a directory having files written.
+type TarFS struct {
+	archiveFS
+	tw *tar.Writer
+	zw io.WriteCloser // nil if not compressed
+}
+
+var _ interface {
+	LchownFS
+	LchtimesFS
+	MknodFS
+	XattrFS
+} = (*TarFS)(nil)
+
+// NewTarFS returns a TarFS writing an archive into w, compressed by compress if not nil.
+// TarFS must be closed by Close to complete the archive, which doesn't close w.
+func NewTarFS(w io.Writer, compress Compressor) (*TarFS, error) {
+	t := &TarFS{}
+	t.archiveFS = newArchiveFS(t.writeEntry)
+	if compress != nil {
+		zw, err := compress(w)
+		if err != nil {
+			return nil, err
+		}
+		t.zw, w = zw, zw
+	}
+	t.tw = tar.NewWriter(w)
+	return t, nil
+}
+
+// Close writes the entries not written yet, and completes the archive.
+func (t *TarFS) Close() error {
+	if ok, err := t.close(); !ok || err != nil {
+		return err
+	}
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	if t.zw != nil {
+		return t.zw.Close()
+	}
+	return nil
+}
+
+// writeEntry writes the header of e and its contents.
+func (t *TarFS) writeEntry(e *archiveEntry, contents io.Reader) error {
+	hdr := &tar.Header{
+		Name:       e.name,
+		Mode:       int64(e.mode.Perm()),
+		Uid:        e.uid,
+		Gid:        e.gid,
+		ModTime:    e.mtime,
+		AccessTime: e.atime,
+		Format:     tar.FormatPAX,
+	}
+	if e.mode&fs.ModeSetuid != 0 {
+		hdr.Mode |= 04000
+	}
+	if e.mode&fs.ModeSetgid != 0 {
+		hdr.Mode |= 02000
+	}
+	if e.mode&fs.ModeSticky != 0 {
+		hdr.Mode |= 01000
+	}
+
+	switch {
+	case e.hardlink:
+		hdr.Typeflag, hdr.Linkname = tar.TypeLink, e.linkname
+	case e.mode.IsDir():
+		hdr.Typeflag, hdr.Name = tar.TypeDir, e.name+"/"
+	case e.mode&fs.ModeSymlink != 0:
+		hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, e.linkname
+	case e.mode&fs.ModeNamedPipe != 0:
+		hdr.Typeflag = tar.TypeFifo
+	case e.mode&fs.ModeCharDevice != 0:
+		hdr.Typeflag = tar.TypeChar
+		hdr.Devmajor, hdr.Devminor = devNumbers(e.rdev)
+	case e.mode&fs.ModeDevice != 0:
+		hdr.Typeflag = tar.TypeBlock
+		hdr.Devmajor, hdr.Devminor = devNumbers(e.rdev)
+	default:
+		hdr.Typeflag, hdr.Size = tar.TypeReg, e.size
+	}
+
+	for attr, value := range e.xattrs {
+		if hdr.PAXRecords == nil {
+			hdr.PAXRecords = map[string]string{}
+		}
+		hdr.PAXRecords[tarXattrPrefix+attr] = value
+	}
+
+	if err := t.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if contents != nil {
+		if _, err := io.Copy(t.tw, contents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *TarFS) Mkfifo(name string, perm fs.FileMode) error {
+	return t.mknod("mkfifo", name, fs.ModeNamedPipe|perm.Perm(), 0)
+}
+
+// Mknod adds a character or block device. Sockets are not supported by tar.
+func (t *TarFS) Mknod(name string, mode fs.FileMode, dev uint64) error {
+	if mode&fs.ModeSocket != 0 {
+		return &fs.PathError{Op: "mknod", Path: name, Err: errors.ErrUnsupported}
+	}
+	return t.mknod("mknod", name, mode, dev)
+}
@@ -0,0 +1,163 @@
+//go:build !aix && !illumos && !js && !netbsd && !plan9 && !solaris && !windows
+
+package copy_go
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// readTar reads all the headers and the contents of regular files in a tar archive.
+func readTar(t *testing.T, r io.Reader) (map[string]*tar.Header, map[string]string) {
+	t.Helper()
+	headers, contents := map[string]*tar.Header{}, map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return headers, contents
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers[hdr.Name] = hdr
+		if hdr.Typeflag == tar.TypeReg {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			contents[hdr.Name] = string(data)
+		}
+	}
+}
+
+func TestCopy_tarFS(t *testing.T) {
+	src := t.TempDir()
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 600, time.UTC)
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "a.txt"), []byte("hello"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(src, "sub", "a.txt"), filepath.Join(src, "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "skipped.log"), []byte("skipped"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/a.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Mkfifo(filepath.Join(src, "fifo"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{filepath.Join("sub", "a.txt"), "sub", "."} {
+		if err := os.Chtimes(filepath.Join(src, name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	tfs, err := NewTarFS(&buf, Gzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := CopyWithResult(src, "out", Options{
+		DestFS:            tfs,
+		PreserveTimes:     true,
+		PreserveOwner:     true,
+		PreserveHardlinks: true,
+		NumOfWorkers:      4,
+		Skip: func(src, dst string, info os.FileInfo) (bool, error) {
+			return strings.HasSuffix(src, ".log"), nil
+		},
+		RenameDestination: func(src, dst string) (string, error) {
+			return strings.Replace(dst, "sub", "renamed", 1), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tfs.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if result.Hardlinks != 1 {
+		t.Errorf("Hardlinks = %d, want 1", result.Hardlinks)
+	}
+
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers, contents := readTar(t, zr)
+
+	file := headers["out/renamed/a.txt"]
+	link := headers["out/b.txt"]
+	if file == nil || link == nil {
+		t.Fatalf("entries = %v", headers)
+	}
+	// Either of them is written as the file, and the other as the link to it.
+	if file.Typeflag == tar.TypeLink {
+		file, link = link, file
+	}
+	if got := contents[file.Name]; got != "hello" {
+		t.Errorf("%s = %q, want %q", file.Name, got, "hello")
+	}
+	if file.Mode != 0640 {
+		t.Errorf("mode = %o, want %o", file.Mode, 0640)
+	}
+	if !file.ModTime.Equal(mtime) {
+		t.Errorf("mtime = %v, want %v", file.ModTime, mtime)
+	}
+	if file.Uid != os.Getuid() || file.Gid != os.Getgid() {
+		t.Errorf("owner = %d:%d, want %d:%d", file.Uid, file.Gid, os.Getuid(), os.Getgid())
+	}
+	if link.Typeflag != tar.TypeLink || link.Linkname != file.Name {
+		t.Errorf("link = %c %q, want a link to %q", link.Typeflag, link.Linkname, file.Name)
+	}
+
+	if dir := headers["out/renamed/"]; dir == nil || dir.Mode != 0750 || !dir.ModTime.Equal(mtime) {
+		t.Errorf("dir = %+v", dir)
+	}
+	if sym := headers["out/link"]; sym == nil || sym.Typeflag != tar.TypeSymlink || sym.Linkname != "sub/a.txt" {
+		t.Errorf("symlink = %+v", sym)
+	}
+	if fifo := headers["out/fifo"]; fifo == nil || fifo.Typeflag != tar.TypeFifo || fifo.Mode != 0600 {
+		t.Errorf("fifo = %+v", fifo)
+	}
+	if _, ok := headers["out/skipped.log"]; ok {
+		t.Error("skipped file is archived")
+	}
+}
+
+func TestTarFS_writtenEntry(t *testing.T) {
+	tfs, err := NewTarFS(io.Discard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := tfs.Create("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tfs.Remove("a.txt"); err == nil {
+		t.Error("removed an entry already written")
+	}
+	if err := tfs.Chmod("a.txt", 0600); err == nil {
+		t.Error("changed an entry already written")
+	}
+	if err := tfs.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
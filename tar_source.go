@@ -0,0 +1,475 @@
+package copy_go
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TarSourceFS is an fs.FS of a tar archive, to be copied from by Options.FS.
+// e.g., to extract an archive:
+//
+//	tfs, err := OpenTarFS(file, size)
+//	err = Copy(".", "your/directory", Options{FS: tfs, PreserveTimes: true, Contained: true})
+//
+// All the headers are indexed when it's opened, and the contents of files are read on demand,
+// so that they can be copied in any order and concurrently.
+// Sparse files are expanded into a temporary file with their holes kept as holes,
+// so it must be closed by Close after use. Sparse files larger than 1TiB are rejected by *UnsafeArchiveError,
+// because their holes still take time to be read through.
+//
+// The entries are checked against tar-slip on open: absolute paths, paths escaping by "..",
+// and paths under a symlink or any other non-directory entry are rejected by *UnsafeArchiveError,
+// as well as hard links to such paths. Symlinks themselves can point anywhere,
+// which are checked by Options.Contained or Options.OutOfTreeSymlink on copying them.
+//
+// fs.FileInfo.Sys() of the entries implements SysTimes, SysOwner, SysRdev, SysInode and SysXattrs,
+// so that uid/gid, times, device numbers, hard links and PAX xattrs are preserved by the Options.
+type TarSourceFS struct {
+	r      io.ReaderAt
+	nodes  map[string]*tarNode
+	spool  *os.File // temporary copy of the archive read by ReadTarFS
+	sparse *os.File // temporary file of the contents of sparse files, made on the first one
+}
+
+// tarNode is an entry of TarSourceFS. Hard links share the same tarInode.
+type tarNode struct {
+	hdr      *tar.Header
+	inode    *tarInode
+	children []string // base names, sorted, of a directory
+}
+
+// tarInode is the contents of a regular file, shared by its hard links.
+type tarInode struct {
+	ino    uint64
+	nlink  uint64
+	offset int64 // of the contents in the archive, or in TarSourceFS.sparse for sparse files
+	size   int64 // of the contents
+	sparse bool
+}
+
+var _ interface {
+	fs.ReadDirFS
+	fs.ReadLinkFS
+	fs.StatFS
+} = (*TarSourceFS)(nil)
+
+// OpenTarFS indexes an uncompressed tar archive of the given size,
+// whose contents are read from r on demand.
+func OpenTarFS(r io.ReaderAt, size int64) (*TarSourceFS, error) {
+	t := &TarSourceFS{
+		r: r,
+		nodes: map[string]*tarNode{
+			".": {hdr: &tar.Header{Typeflag: tar.TypeDir, Name: "./", Mode: 0755}},
+		},
+	}
+	if err := t.index(io.NewSectionReader(r, 0, size)); err != nil {
+		_ = t.Close()
+		return nil, err
+	}
+	for _, n := range t.nodes {
+		slices.Sort(n.children)
+	}
+	return t, nil
+}
+
+// ReadTarFS indexes a tar archive read from a stream, e.g. gzip.Reader,
+// by copying it into a temporary file, which is removed by Close.
+func ReadTarFS(r io.Reader) (t *TarSourceFS, err error) {
+	spool, err := os.CreateTemp("", "copy-go-tar-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			_ = spool.Close()
+			_ = os.Remove(spool.Name())
+		}
+	}()
+
+	size, err := io.Copy(spool, r)
+	if err != nil {
+		return nil, err
+	}
+	if t, err = OpenTarFS(spool, size); err != nil {
+		return nil, err
+	}
+	t.spool = spool
+	return t, nil
+}
+
+// Close removes the temporary files made by ReadTarFS and for sparse files.
+func (t *TarSourceFS) Close() error {
+	var errs []error
+	for _, f := range []*os.File{t.spool, t.sparse} {
+		if f != nil {
+			errs = append(errs, f.Close(), os.Remove(f.Name()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// index reads all the headers, with keeping where the contents are.
+func (t *TarSourceFS) index(r *io.SectionReader) error {
+	tr := tar.NewReader(r)
+	for ino := uint64(1); ; ino++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name, err := tarEntryName(hdr.Name)
+		if err != nil {
+			return err
+		}
+
+		var inode *tarInode
+		switch hdr.Typeflag {
+		case tar.TypeReg, tar.TypeGNUSparse:
+			inode = &tarInode{ino: ino, size: hdr.Size}
+			if inode.sparse = isSparse(hdr); inode.sparse {
+				if hdr.Size > maxTarSparseSize {
+					return &UnsafeArchiveError{Name: hdr.Name, Reason: "sparse file too large"}
+				}
+				if inode.offset, err = t.expandSparse(tr); err != nil {
+					return err
+				}
+			} else if inode.offset, err = r.Seek(0, io.SeekCurrent); err != nil {
+				return err
+			}
+			hdr.Typeflag = tar.TypeReg
+		case tar.TypeLink:
+			target, err := tarEntryName(hdr.Linkname)
+			if err != nil {
+				return err
+			}
+			orig, ok := t.nodes[target]
+			if !ok || orig.inode == nil {
+				return &UnsafeArchiveError{Name: hdr.Name, Reason: "hard link to a missing file " + hdr.Linkname}
+			}
+			inode = orig.inode
+			hdr.Typeflag, hdr.Size = tar.TypeReg, inode.size
+		case tar.TypeDir, tar.TypeSymlink, tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		default:
+			continue // e.g. tar.TypeCont, not supported
+		}
+
+		if err := t.add(name, hdr, inode); err != nil {
+			return err
+		}
+	}
+}
+
+// maxTarSparseSize is the largest sparse file TarSourceFS accepts.
+const maxTarSparseSize = 1 << 40
+
+// expandSparse writes the contents of the current sparse file of tr at the end of t.sparse,
+// and returns where they are. The blocks of zeros are skipped by Seek instead of written,
+// so neither the memory nor the disk is used up by a small archive of a huge sparse file.
+func (t *TarSourceFS) expandSparse(tr *tar.Reader) (int64, error) {
+	if t.sparse == nil {
+		f, err := os.CreateTemp("", "copy-go-tar-sparse-*")
+		if err != nil {
+			return 0, err
+		}
+		t.sparse = f
+	}
+	offset, err := t.sparse.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	buf, zeros := make([]byte, 64*1024), make([]byte, 64*1024)
+	end := offset
+	for {
+		n, err := tr.Read(buf)
+		if n > 0 {
+			var werr error
+			if bytes.Equal(buf[:n], zeros[:n]) {
+				_, werr = t.sparse.Seek(int64(n), io.SeekCurrent)
+			} else {
+				_, werr = t.sparse.Write(buf[:n])
+			}
+			if werr != nil {
+				return 0, werr
+			}
+			end += int64(n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	return offset, t.sparse.Truncate(end) // for the hole at the end
+}
+
+// add adds an entry, whose parent directories are made implicitly if missing.
+// A later entry of the same name replaces the earlier one, as tar does.
+func (t *TarSourceFS) add(name string, hdr *tar.Header, inode *tarInode) error {
+	if name == "." {
+		if hdr.Typeflag == tar.TypeDir {
+			t.nodes["."].hdr = hdr
+			return nil
+		}
+		return &UnsafeArchiveError{Name: hdr.Name, Reason: "not a directory at the top"}
+	}
+
+	parent := path.Dir(name)
+	if err := t.addParent(parent, hdr.Name); err != nil {
+		return err
+	}
+
+	if orig, ok := t.nodes[name]; ok {
+		if orig.hdr.Typeflag == tar.TypeDir {
+			if hdr.Typeflag != tar.TypeDir {
+				return &UnsafeArchiveError{Name: hdr.Name, Reason: "replaces a directory"}
+			}
+			orig.hdr = hdr
+			return nil
+		}
+		if orig.inode != nil {
+			orig.inode.nlink--
+		}
+	} else {
+		t.nodes[parent].children = append(t.nodes[parent].children, path.Base(name))
+	}
+
+	if inode != nil {
+		inode.nlink++
+	}
+	t.nodes[name] = &tarNode{hdr: hdr, inode: inode}
+	return nil
+}
+
+// addParent makes sure that dir is a directory, by making it if missing.
+func (t *TarSourceFS) addParent(dir, entry string) error {
+	if n, ok := t.nodes[dir]; ok {
+		if n.hdr.Typeflag != tar.TypeDir {
+			return &UnsafeArchiveError{Name: entry, Reason: "under a non-directory " + dir}
+		}
+		return nil
+	}
+	if err := t.addParent(path.Dir(dir), entry); err != nil {
+		return err
+	}
+	parent := t.nodes[path.Dir(dir)]
+	parent.children = append(parent.children, path.Base(dir))
+	t.nodes[dir] = &tarNode{hdr: &tar.Header{Typeflag: tar.TypeDir, Name: dir + "/", Mode: 0755}}
+	return nil
+}
+
+// tarEntryName cleans the name of an entry, and rejects it if it's not safe to extract.
+// Backslashes are regarded as separators too, not to be escaped on windows.
+func tarEntryName(name string) (string, error) {
+	slashed := strings.ReplaceAll(name, `\`, "/")
+	if path.IsAbs(slashed) || (len(slashed) >= 2 && slashed[1] == ':') {
+		return "", &UnsafeArchiveError{Name: name, Reason: "absolute path"}
+	}
+	cleaned := path.Clean(slashed)
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", &UnsafeArchiveError{Name: name, Reason: "path escaping by .."}
+	}
+	return cleaned, nil
+}
+
+// isSparse tells if the contents of the entry are sparse, which can't be read from the archive as they are.
+func isSparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve follows the symlinks in name, and the last one only if followLast.
+func (t *TarSourceFS) resolve(name string, followLast bool) (string, error) {
+	return resolveRooted(name, followLast, func(name string) (string, bool) {
+		n, ok := t.nodes[name]
+		if !ok || n.hdr.Typeflag != tar.TypeSymlink {
+			return "", false
+		}
+		return n.hdr.Linkname, true
+	})
+}
+
+// lookup returns the node of the resolved name.
+func (t *TarSourceFS) lookup(op, name string, followLast bool) (*tarNode, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	resolved, err := t.resolve(name, followLast)
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	n, ok := t.nodes[resolved]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return n, nil
+}
+
+func (t *TarSourceFS) Open(name string) (fs.File, error) {
+	n, err := t.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info := &tarInfo{name: path.Base(name), node: n}
+	switch {
+	case n.hdr.Typeflag == tar.TypeDir:
+		entries, err := t.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &memDir{info: info, entries: entries}, nil
+	case n.inode == nil:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.ErrUnsupported}
+	case n.inode.sparse:
+		return &tarOpenFile{info: info, SectionReader: io.NewSectionReader(t.sparse, n.inode.offset, n.inode.size)}, nil
+	default:
+		return &tarOpenFile{info: info, SectionReader: io.NewSectionReader(t.r, n.inode.offset, n.inode.size)}, nil
+	}
+}
+
+func (t *TarSourceFS) Stat(name string) (fs.FileInfo, error) {
+	n, err := t.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return &tarInfo{name: path.Base(name), node: n}, nil
+}
+
+func (t *TarSourceFS) Lstat(name string) (fs.FileInfo, error) {
+	n, err := t.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return &tarInfo{name: path.Base(name), node: n}, nil
+}
+
+func (t *TarSourceFS) ReadLink(name string) (string, error) {
+	n, err := t.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n.hdr.Typeflag != tar.TypeSymlink {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return n.hdr.Linkname, nil
+}
+
+func (t *TarSourceFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	n, err := t.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if n.hdr.Typeflag != tar.TypeDir {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	resolved, _ := t.resolve(name, true)
+	entries := make([]fs.DirEntry, 0, len(n.children))
+	for _, child := range n.children {
+		info := &tarInfo{name: child, node: t.nodes[path.Join(resolved, child)]}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	return entries, nil
+}
+
+// tarOpenFile is an fs.File of a regular file of TarSourceFS.
+// It's an io.ReaderAt, so that large files can be copied in chunks.
+type tarOpenFile struct {
+	*io.SectionReader
+	info fs.FileInfo
+}
+
+func (f *tarOpenFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *tarOpenFile) Close() error {
+	return nil
+}
+
+// tarInfo is an entry of TarSourceFS as fs.FileInfo.
+// Its Sys() implements SysTimes, SysOwner, SysRdev, SysInode and SysXattrs.
+type tarInfo struct {
+	name string
+	node *tarNode
+}
+
+func (i *tarInfo) Name() string       { return i.name }
+func (i *tarInfo) Mode() fs.FileMode  { return i.node.hdr.FileInfo().Mode() }
+func (i *tarInfo) ModTime() time.Time { return i.node.hdr.ModTime }
+func (i *tarInfo) IsDir() bool        { return i.node.hdr.Typeflag == tar.TypeDir }
+func (i *tarInfo) Sys() any           { return tarSys{i.node} }
+
+func (i *tarInfo) Size() int64 {
+	if i.node.inode != nil {
+		return i.node.inode.size
+	}
+	return 0
+}
+
+// tarSys is the Sys() of tarInfo.
+type tarSys struct {
+	node *tarNode
+}
+
+// AccessTime returns the access time, or the modification time if it's not recorded.
+func (s tarSys) AccessTime() time.Time {
+	if s.node.hdr.AccessTime.IsZero() {
+		return s.node.hdr.ModTime
+	}
+	return s.node.hdr.AccessTime
+}
+
+// ChangeTime returns the change time, or the modification time if it's not recorded.
+func (s tarSys) ChangeTime() time.Time {
+	if s.node.hdr.ChangeTime.IsZero() {
+		return s.node.hdr.ModTime
+	}
+	return s.node.hdr.ChangeTime
+}
+
+func (s tarSys) Owner() (uid, gid int) {
+	return s.node.hdr.Uid, s.node.hdr.Gid
+}
+
+func (s tarSys) Rdev() uint64 {
+	return makeDev(s.node.hdr.Devmajor, s.node.hdr.Devminor)
+}
+
+// Inode returns the number of the file unique in the archive, and its number of hard links.
+// The entries other than regular files are never hard links.
+func (s tarSys) Inode() (dev, ino, nlink uint64) {
+	if s.node.inode == nil {
+		return 0, 0, 1
+	}
+	return 0, s.node.inode.ino, s.node.inode.nlink
+}
+
+// Xattrs returns the extended attributes recorded by PAX records of SCHILY.xattr.
+func (s tarSys) Xattrs() map[string]string {
+	xattrs := map[string]string{}
+	for key, value := range s.node.hdr.PAXRecords {
+		if attr, ok := strings.CutPrefix(key, tarXattrPrefix); ok {
+			xattrs[attr] = value
+		}
+	}
+	return xattrs
+}
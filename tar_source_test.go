@@ -0,0 +1,228 @@
+//go:build !windows && !plan9 && !js
+
+package copy_go
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// makeTar makes a tar archive of the headers, with the contents of regular files.
+func makeTar(t *testing.T, entries ...*tar.Header) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range entries {
+		data := hdr.Linkname
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size, hdr.Linkname = int64(len(data)), ""
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			if _, err := tw.Write([]byte(data)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCopy_tarSource(t *testing.T) {
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	uid, gid := os.Getuid(), os.Getgid()
+	archive := makeTar(t,
+		&tar.Header{Typeflag: tar.TypeDir, Name: "dir/", Mode: 0750, ModTime: mtime},
+		// contents of regular files are given by Linkname
+		&tar.Header{Typeflag: tar.TypeReg, Name: "dir/a.txt", Linkname: "hello", Mode: 0640, ModTime: mtime, Uid: uid, Gid: gid},
+		&tar.Header{Typeflag: tar.TypeLink, Name: "b.txt", Linkname: "dir/a.txt"},
+		&tar.Header{Typeflag: tar.TypeSymlink, Name: "link", Linkname: "dir/a.txt", ModTime: mtime},
+		&tar.Header{Typeflag: tar.TypeFifo, Name: "fifo", Mode: 0600},
+		&tar.Header{Typeflag: tar.TypeReg, Name: "implicit/c.txt", Linkname: "world", Mode: 0644},
+	)
+
+	tfs, err := OpenTarFS(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "dst")
+	result, err := CopyWithResult(".", dst, Options{
+		FS:                tfs,
+		PreserveTimes:     true,
+		PreserveOwner:     true,
+		PreserveHardlinks: true,
+		Contained:         true,
+		NumOfWorkers:      4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Files != 2 || result.Hardlinks != 1 || result.Symlinks != 1 || result.NamedPipes != 1 {
+		t.Errorf("result = %+v", result)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dst, "dir", "a.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Errorf("dir/a.txt = %q, %v", data, err)
+	}
+	info, _ := os.Stat(filepath.Join(dst, "dir", "a.txt"))
+	if info.Mode().Perm() != 0640 || !info.ModTime().Equal(mtime) {
+		t.Errorf("dir/a.txt: mode = %v, mtime = %v", info.Mode(), info.ModTime())
+	}
+	if linked, err := os.Stat(filepath.Join(dst, "b.txt")); err != nil || !os.SameFile(info, linked) {
+		t.Errorf("b.txt is not a hard link to dir/a.txt: %v", err)
+	}
+	if dir, _ := os.Stat(filepath.Join(dst, "dir")); dir.Mode().Perm() != 0750 || !dir.ModTime().Equal(mtime) {
+		t.Errorf("dir: mode = %v, mtime = %v", dir.Mode(), dir.ModTime())
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "link")); err != nil || target != "dir/a.txt" {
+		t.Errorf("link = %q, %v", target, err)
+	}
+	if fifo, err := os.Lstat(filepath.Join(dst, "fifo")); err != nil || fifo.Mode()&os.ModeNamedPipe == 0 {
+		t.Errorf("fifo = %v, %v", fifo, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "implicit", "c.txt")); err != nil || string(data) != "world" {
+		t.Errorf("implicit/c.txt = %q, %v", data, err)
+	}
+}
+
+func TestCopy_tarSourceRoundTrip(t *testing.T) {
+	mem := NewMemFS()
+	if err := mem.MkdirAll("src/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, _ := mem.Create("src/sub/a.txt")
+	_, _ = f.Write([]byte("hello"))
+	_ = f.Close()
+
+	var buf bytes.Buffer
+	tw, err := NewTarFS(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Copy("src", "src", Options{FS: mem, DestFS: tw}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tfs, err := ReadTarFS(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Close()
+	out := NewMemFS()
+	if err := Copy("src", "dst", Options{FS: tfs, DestFS: out}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fs.ReadFile(out, "dst/sub/a.txt"); err != nil || string(data) != "hello" {
+		t.Errorf("dst/sub/a.txt = %q, %v", data, err)
+	}
+}
+
+func TestOpenTarFS_unsafe(t *testing.T) {
+	for name, entries := range map[string][]*tar.Header{
+		"absolute":             {{Typeflag: tar.TypeReg, Name: "/etc/passwd"}},
+		"dotdot":               {{Typeflag: tar.TypeReg, Name: "a/../../evil"}},
+		"backslash":            {{Typeflag: tar.TypeReg, Name: `..\evil`}},
+		"hardlink":             {{Typeflag: tar.TypeLink, Name: "a", Linkname: "../../etc/passwd"}},
+		"missing":              {{Typeflag: tar.TypeLink, Name: "a", Linkname: "b"}},
+		"throughdir":           {{Typeflag: tar.TypeSymlink, Name: "a", Linkname: "/etc"}, {Typeflag: tar.TypeReg, Name: "a/passwd"}},
+		"throughdir backslash": {{Typeflag: tar.TypeSymlink, Name: "a", Linkname: "/etc"}, {Typeflag: tar.TypeReg, Name: `a\passwd`}},
+	} {
+		archive := makeTar(t, entries...)
+		_, err := OpenTarFS(bytes.NewReader(archive), int64(len(archive)))
+		var unsafe *UnsafeArchiveError
+		if !errors.As(err, &unsafe) {
+			t.Errorf("%s: err = %v, want *UnsafeArchiveError", name, err)
+		}
+	}
+}
+
+// makeSparseTar makes a tar archive of a PAX 1.0 sparse file named "sparse" of realSize,
+// with "hello" at offset. tar.Writer doesn't write the records of sparse files,
+// so they are written as "XXX.sparse." and renamed.
+func makeSparseTar(t *testing.T, realSize, offset int64) []byte {
+	t.Helper()
+	body := fmt.Sprintf("1\n%d\n5\n", offset)
+	body += strings.Repeat("\x00", 512-len(body)) + "hello"
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     "GNUSparseFile.0/sparse",
+		Size:     int64(len(body)),
+		Format:   tar.FormatPAX,
+		PAXRecords: map[string]string{
+			"XXX.sparse.major":    "1",
+			"XXX.sparse.minor":    "0",
+			"XXX.sparse.name":     "sparse",
+			"XXX.sparse.realsize": strconv.FormatInt(realSize, 10),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return bytes.ReplaceAll(buf.Bytes(), []byte("XXX.sparse."), []byte("GNU.sparse."))
+}
+
+func TestOpenTarFS_sparse(t *testing.T) {
+	const realSize, offset = 16 << 20, 1 << 20
+	archive := makeSparseTar(t, realSize, offset)
+	tfs, err := OpenTarFS(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tfs.Close()
+	if info, err := fs.Stat(tfs, "sparse"); err != nil || info.Size() != realSize {
+		t.Fatalf("Stat(sparse) = %v, %v, want the size %d", info, err, realSize)
+	}
+
+	f, err := tfs.Open("sparse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got := make([]byte, 10)
+	if _, err := f.(io.ReaderAt).ReadAt(got, offset-5); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\x00\x00\x00\x00\x00hello"; string(got) != want {
+		t.Errorf("ReadAt() = %q, want %q", got, want)
+	}
+	if _, err := f.(io.ReaderAt).ReadAt(got, realSize-10); err != nil || !bytes.Equal(got, make([]byte, 10)) {
+		t.Errorf("ReadAt() at the end = %q, %v, want zeros", got, err)
+	}
+	if info, err := tfs.sparse.Stat(); err != nil || info.Sys().(*syscall.Stat_t).Blocks*512 >= realSize/2 {
+		t.Errorf("the holes are written: %v, %v", info, err)
+	}
+
+	// a few hundred bytes of a terabyte file are rejected, rather than read through
+	archive = makeSparseTar(t, 2<<40, offset)
+	var unsafe *UnsafeArchiveError
+	if _, err := OpenTarFS(bytes.NewReader(archive), int64(len(archive))); !errors.As(err, &unsafe) {
+		t.Errorf("OpenTarFS() = %v, want *UnsafeArchiveError", err)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build !windows && !plan9 && !js && !wasip1
+
+package copy_go
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// processUmask is read on init, before any copy can be running,
+// because reading it by syscall.Umask sets it to 0 for a moment for the whole process.
+var processUmask = readUmask()
+
+// getUmask returns the umask of the process.
+func getUmask() fs.FileMode {
+	return processUmask
+}
+
+// readUmask reads the umask from /proc/self/status on linux, or by setting it and setting it back.
+func readUmask() fs.FileMode {
+	if f, err := os.Open("/proc/self/status"); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if value, ok := strings.CutPrefix(scanner.Text(), "Umask:"); ok {
+				if mask, err := strconv.ParseUint(strings.TrimSpace(value), 8, 32); err == nil {
+					return fs.FileMode(mask) & fs.ModePerm
+				}
+			}
+		}
+	}
+	mask := syscall.Umask(0) // no other way to read it than setting it
+	syscall.Umask(mask)
+	return fs.FileMode(mask) & fs.ModePerm
+}
@@ -0,0 +1,9 @@
+//go:build windows || plan9 || js || wasip1
+
+package copy_go
+
+import "io/fs"
+
+func getUmask() fs.FileMode {
+	return 0 // no umask on this platform
+}
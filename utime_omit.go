@@ -0,0 +1,8 @@
+//go:build unix && !darwin && !netbsd
+
+package copy_go
+
+import "golang.org/x/sys/unix"
+
+// utimeOmit is UTIME_OMIT of utimensat(2), which leaves the time as it is.
+const utimeOmit = unix.UTIME_OMIT
@@ -0,0 +1,4 @@
+package copy_go
+
+// utimeOmit is UTIME_OMIT of utimensat(2) in <sys/stat.h>, which golang.org/x/sys/unix doesn't define on netbsd.
+const utimeOmit = (1 << 30) - 2
@@ -0,0 +1,182 @@
+package copy_go
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// WritableFS is a filesystem which Copy writes the copies into, see Options.DestFS.
+// The names given to its methods are dst, and the paths under dst joined by filepath.Join.
+//
+// Besides the methods below, a WritableFS can implement LchownFS, LchtimesFS, MknodFS, XattrFS and InodeFlagsFS,
+// otherwise owner and times of symlinks are not preserved,
+// named pipes and devices are not created, and extended attributes and inode flags are not preserved.
+type WritableFS interface {
+	// Create creates or truncates the named file.
+	Create(name string) (WritableFile, error)
+	Mkdir(name string, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+	Chmod(name string, mode fs.FileMode) error
+	Chown(name string, uid, gid int) error
+	Chtimes(name string, atime, mtime time.Time) error
+	// Symlink creates newname as a symbolic link to oldname.
+	Symlink(oldname, newname string) error
+	// Link creates newname as a hard link to oldname.
+	Link(oldname, newname string) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldpath, newpath string) error
+	// Lstat returns the info of the named file, without following a symlink.
+	// A missing file must be reported by an error satisfying errors.Is(err, fs.ErrNotExist).
+	Lstat(name string) (fs.FileInfo, error)
+}
+
+// WritableFile is a file created by WritableFS.Create.
+// If it also implements io.WriterAt and Truncate(size int64) error,
+// large files can be copied in chunks, see Options.ChunkThreshold.
+type WritableFile interface {
+	io.Writer
+	io.Closer
+	Sync() error
+}
+
+// LchownFS is a WritableFS which can change the owner of a symlink itself.
+type LchownFS interface {
+	WritableFS
+	Lchown(name string, uid, gid int) error
+}
+
+// LchtimesFS is a WritableFS which can change the times of a symlink itself.
+type LchtimesFS interface {
+	WritableFS
+	Lchtimes(name string, atime, mtime time.Time) error
+}
+
+// MknodFS is a WritableFS which can create named pipes, devices and sockets.
+type MknodFS interface {
+	WritableFS
+	Mkfifo(name string, perm fs.FileMode) error
+	// Mknod creates a device or a socket, of the type given by mode, with the device number dev.
+	Mknod(name string, mode fs.FileMode, dev uint64) error
+}
+
+// XattrFS is a WritableFS which can set extended attributes, see Options.PreserveXattrs.
+// An error satisfying errors.Is(err, errors.ErrUnsupported) means that they are not supported,
+// and they are silently ignored.
+type XattrFS interface {
+	WritableFS
+	Lsetxattr(name, attr string, value []byte) error
+}
+
+// InodeFlagsFS is a WritableFS which can set the inode flags of chattr(1), see Options.PreserveFlags.
+// SetInodeFlags sets the flags in mask to flags, leaving the others as they are.
+// An error satisfying errors.Is(err, errors.ErrUnsupported) is reported in Result.Warnings.
+type InodeFlagsFS interface {
+	WritableFS
+	SetInodeFlags(name string, flags, mask uint32) error
+}
+
+// chunkWriter is a WritableFile which large files can be copied into in chunks.
+type chunkWriter interface {
+	io.WriterAt
+	Truncate(size int64) error
+}
+
+// exists tells if name exists in dfs, regarding errors other than fs.ErrNotExist as existing.
+func exists(dfs WritableFS, name string) bool {
+	_, err := dfs.Lstat(name)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+// isOSFS tells if dfs writes to the OS filesystem as it is.
+func isOSFS(dfs WritableFS) bool {
+	_, ok := dfs.(OSFS)
+	return dfs == nil || ok
+}
+
+// OSFS is the WritableFS of the OS filesystem, which is the default of Options.DestFS.
+type OSFS struct{}
+
+var _ interface {
+	LchownFS
+	LchtimesFS
+	MknodFS
+	XattrFS
+	InodeFlagsFS
+} = OSFS{}
+
+func (OSFS) Create(name string) (WritableFile, error) {
+	return os.Create(name)
+}
+
+func (OSFS) Mkdir(name string, perm fs.FileMode) error {
+	return os.Mkdir(name, perm)
+}
+
+func (OSFS) MkdirAll(name string, perm fs.FileMode) error {
+	return os.MkdirAll(name, perm)
+}
+
+func (OSFS) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (OSFS) Chown(name string, uid, gid int) error {
+	return os.Chown(name, uid, gid)
+}
+
+func (OSFS) Lchown(name string, uid, gid int) error {
+	return os.Lchown(name, uid, gid)
+}
+
+// Chtimes changes the times in nanoseconds, as os.Chtimes calls utimensat(2) on unix.
+func (OSFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (OSFS) Lchtimes(name string, atime, mtime time.Time) error {
+	return lchtimes(name, atime, mtime)
+}
+
+func (OSFS) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, newname)
+}
+
+func (OSFS) Link(oldname, newname string) error {
+	return os.Link(oldname, newname)
+}
+
+func (OSFS) Remove(name string) error {
+	return os.Remove(name)
+}
+
+func (OSFS) RemoveAll(name string) error {
+	return os.RemoveAll(name)
+}
+
+func (OSFS) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (OSFS) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (OSFS) Mkfifo(name string, perm fs.FileMode) error {
+	return mkfifo(name, perm)
+}
+
+func (OSFS) Mknod(name string, mode fs.FileMode, dev uint64) error {
+	return mknod(name, mode, dev)
+}
+
+func (OSFS) Lsetxattr(name, attr string, value []byte) error {
+	return lsetxattr(name, attr, value)
+}
+
+func (OSFS) SetInodeFlags(name string, flags, mask uint32) error {
+	return setInodeFlags(name, flags, mask)
+}
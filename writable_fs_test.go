@@ -0,0 +1,191 @@
+package copy_go
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopy_memFS(t *testing.T) {
+	src := t.TempDir()
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "sub", "a.txt"), []byte("hello"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(filepath.Join(src, "sub", "a.txt"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/a.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	mem := NewMemFS()
+	err := Copy(src, "/dst", Options{
+		DestFS:        mem,
+		OnSymlink:     func(string) SymlinkAction { return Shallow },
+		PreserveTimes: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fs.ReadFile(mem, "dst/sub/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("content = %q, want %q", data, "hello")
+	}
+	info, err := fs.Stat(mem, "dst/sub/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime = %v, want %v", info.ModTime(), mtime)
+	}
+	if target, err := mem.ReadLink("dst/link"); err != nil || target != "sub/a.txt" {
+		t.Errorf("link = %q, %v, want %q", target, err, "sub/a.txt")
+	}
+	if _, err := os.Lstat("/dst"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("copied into the disk: %v", err)
+	}
+}
+
+func TestCopy_memFSRoundTrip(t *testing.T) {
+	mem := NewMemFS()
+	if err := mem.MkdirAll("src/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := mem.Create("src/sub/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.Symlink("sub/a.txt", "src/link"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = Copy("src", "dst", Options{
+		FS:        mem,
+		DestFS:    mem,
+		OnSymlink: func(string) SymlinkAction { return Shallow },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fs.ReadFile(mem, "dst/link"); err != nil || string(data) != "hello" {
+		t.Errorf("dst/link = %q, %v, want %q", data, err, "hello")
+	}
+	info, err := mem.Lstat("dst/link")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&fs.ModeSymlink == 0 {
+		t.Errorf("dst/link is not a symlink: %v", info.Mode())
+	}
+}
+
+func TestCopy_baseDirFS(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	base := t.TempDir()
+	dfs, err := NewBaseDirFS(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dfs.Close()
+
+	if err := Copy(src, "/dst", Options{DestFS: dfs}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(base, "dst", "a.txt")); err != nil || string(data) != "hello" {
+		t.Errorf("dst/a.txt = %q, %v, want %q", data, err, "hello")
+	}
+
+	// "../" is kept inside the root.
+	if err := Copy(src, "../escaped", Options{DestFS: dfs}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(base, "escaped", "a.txt")); err != nil {
+		t.Error(err)
+	}
+
+	// Symlinks are not followed outside of the root.
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(base, "out")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Copy(src, "/out/dst", Options{DestFS: dfs}); err == nil {
+		t.Error("copied through a symlink out of the root")
+	}
+	if _, err := os.Lstat(filepath.Join(outside, "dst")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("written out of the root: %v", err)
+	}
+}
+
+func TestCopy_permissionControl(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// a PermissionControlFunc of the signature before WritableFS, working on the OS filesystem
+	dst := filepath.Join(t.TempDir(), "dst")
+	var custom PermissionControlFunc = func(srcinfo fs.FileInfo, dst string) (func(*error), error) {
+		if srcinfo.IsDir() {
+			if err := os.MkdirAll(dst, 0755); err != nil {
+				return func(*error) {}, err
+			}
+		}
+		return func(err *error) {
+			if e := os.Chmod(dst, 0640); *err == nil {
+				*err = e
+			}
+		}, nil
+	}
+	if err := Copy(src, dst, Options{PermissionControl: custom}); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(dst, "a.txt")); err != nil || info.Mode().Perm() != 0640 {
+		t.Errorf("a.txt = %v, %v, want mode 0640", info, err)
+	}
+
+	mem := NewMemFS()
+	err := Copy(src, "dst", Options{
+		DestFS:            mem,
+		PermissionControl: custom, // not used
+		DestPermissionControl: func(srcinfo fs.FileInfo, dst string, dfs WritableFS) (func(*error), error) {
+			if srcinfo.IsDir() {
+				if err := dfs.MkdirAll(dst, 0755); err != nil {
+					return func(*error) {}, err
+				}
+			}
+			return func(err *error) {
+				chmod(dfs, dst, 0604, err)
+			}, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := mem.Lstat("dst/a.txt"); err != nil || info.Mode().Perm() != 0604 {
+		t.Errorf("dst/a.txt = %v, %v, want mode 0604", info, err)
+	}
+}
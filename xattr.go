@@ -0,0 +1,44 @@
+package copy_go
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"slices"
+)
+
+// preserveXattrs copies the extended attributes of src to dst,
+// if both of the source and the WritableFS support them.
+func preserveXattrs(src, dst string, info os.FileInfo, opt Options) error {
+	xfs, ok := opt.DestFS.(XattrFS)
+	if !ok {
+		return nil
+	}
+	xattrs, err := getXattrs(src, info, opt)
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			return nil
+		}
+		return err
+	}
+	for _, attr := range slices.Sorted(maps.Keys(xattrs)) {
+		if err := xfs.Lsetxattr(dst, attr, []byte(xattrs[attr])); err != nil {
+			if errors.Is(err, errors.ErrUnsupported) {
+				return nil // the destination filesystem doesn't support xattrs
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// getXattrs returns the extended attributes of src, from fs.FileInfo.Sys() if it's SysXattrs.
+func getXattrs(src string, info os.FileInfo, opt Options) (map[string]string, error) {
+	if sys, ok := info.Sys().(SysXattrs); ok {
+		return sys.Xattrs(), nil
+	}
+	if opt.FS != nil {
+		return nil, nil
+	}
+	return listXattrs(src)
+}
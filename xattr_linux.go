@@ -0,0 +1,60 @@
+//go:build linux
+
+package copy_go
+
+import (
+	"bytes"
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// listXattrs reads all the extended attributes of the file, without following a symlink.
+func listXattrs(name string) (map[string]string, error) {
+	size, err := unix.Llistxattr(name, nil)
+	if err != nil || size == 0 {
+		return nil, xattrError("llistxattr", name, err)
+	}
+	buf := make([]byte, size)
+	if size, err = unix.Llistxattr(name, buf); err != nil {
+		return nil, xattrError("llistxattr", name, err)
+	}
+
+	xattrs := map[string]string{}
+	for _, attr := range bytes.Split(buf[:size], []byte{0}) {
+		if len(attr) == 0 {
+			continue
+		}
+		value, err := lgetxattr(name, string(attr))
+		if errors.Is(err, unix.ENODATA) {
+			continue // removed in the meantime
+		}
+		if err != nil {
+			return nil, xattrError("lgetxattr", name, err)
+		}
+		xattrs[string(attr)] = string(value)
+	}
+	return xattrs, nil
+}
+
+func lgetxattr(name, attr string) ([]byte, error) {
+	size, err := unix.Lgetxattr(name, attr, nil)
+	if err != nil {
+		return nil, err
+	}
+	value := make([]byte, size)
+	size, err = unix.Lgetxattr(name, attr, value)
+	return value[:size], err
+}
+
+func lsetxattr(name, attr string, value []byte) error {
+	return xattrError("lsetxattr", name, unix.Lsetxattr(name, attr, value, 0))
+}
+
+func xattrError(op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
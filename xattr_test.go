@@ -0,0 +1,57 @@
+//go:build linux
+
+package copy_go
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestCopy_preserveXattrs(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(src, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.Setxattr(src, "user.test", []byte("value"), 0); err != nil {
+		if errors.Is(err, errors.ErrUnsupported) {
+			t.Skip("xattrs are not supported:", err)
+		}
+		t.Fatal(err)
+	}
+
+	// from the disk into a tar archive, and from the archive into the disk again
+	var buf bytes.Buffer
+	tw, err := NewTarFS(&buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Copy(src, "a.txt", Options{DestFS: tw, PreserveXattrs: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	headers, _ := readTar(t, bytes.NewReader(buf.Bytes()))
+	if got := headers["a.txt"].PAXRecords["SCHILY.xattr.user.test"]; got != "value" {
+		t.Errorf("PAX record = %q, want %q", got, "value")
+	}
+
+	tfs, err := OpenTarFS(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "a.txt")
+	if err := Copy("a.txt", dst, Options{FS: tfs, PreserveXattrs: true}); err != nil {
+		t.Fatal(err)
+	}
+	value := make([]byte, 16)
+	n, err := unix.Getxattr(dst, "user.test", value)
+	if err != nil || string(value[:n]) != "value" {
+		t.Errorf("xattr = %q, %v, want %q", value[:n], err, "value")
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package copy_go
+
+import (
+	"errors"
+	"os"
+)
+
+func listXattrs(name string) (map[string]string, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func lsetxattr(name, attr string, value []byte) error {
+	return &os.PathError{Op: "lsetxattr", Path: name, Err: errors.ErrUnsupported}
+}
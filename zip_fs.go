@@ -0,0 +1,109 @@
+package copy_go
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ZipFS is a WritableFS which writes the copied tree into a zip archive, see NewZipFS.
+// e.g.,
+//
+//	zfs := NewZipFS(w, ZipStoreExtensions(".jpg", ".png", ".gz"))
+//	err = Copy("your/directory", "directory", Options{DestFS: zfs, PreserveTimes: true})
+//	err = zfs.Close()
+//
+// Names are cleaned and rooted at the top of the archive, as MemFS does.
+// The modification time and the unix mode are stored in the entries, in the external attributes,
+// and symlinks are stored as symlink entries whose contents are their targets.
+// Owner, access times, hard links, extended attributes, named pipes and devices can't be stored:
+// hard links are copied as files, and named pipes and devices are reported as skipped.
+//
+// As TarFS does, a regular file is written as soon as it's closed,
+// and the other entries are written when ZipFS is closed.
+type ZipFS struct {
+	archiveFS
+	zw     *zip.Writer
+	method func(name string, size int64) uint16
+}
+
+var _ interface {
+	LchownFS
+	LchtimesFS
+	XattrFS
+} = (*ZipFS)(nil)
+
+// NewZipFS returns a ZipFS writing an archive into w.
+// method chooses the compression method of each file, zip.Store or zip.Deflate,
+// by its name in the archive and its size. If nil, all the files are deflated.
+// ZipFS must be closed by Close to complete the archive, which doesn't close w.
+func NewZipFS(w io.Writer, method func(name string, size int64) uint16) *ZipFS {
+	z := &ZipFS{zw: zip.NewWriter(w), method: method}
+	z.archiveFS = newArchiveFS(z.writeEntry)
+	return z
+}
+
+// ZipStoreExtensions returns a method for NewZipFS,
+// which stores the files of the extensions (e.g. ".jpg") as they are, and deflates the others.
+// It's handy not to compress the files already compressed.
+func ZipStoreExtensions(exts ...string) func(name string, size int64) uint16 {
+	return func(name string, size int64) uint16 {
+		if slices.Contains(exts, strings.ToLower(path.Ext(name))) {
+			return zip.Store
+		}
+		return zip.Deflate
+	}
+}
+
+// Close writes the entries not written yet, and completes the archive.
+func (z *ZipFS) Close() error {
+	if ok, err := z.close(); !ok || err != nil {
+		return err
+	}
+	return z.zw.Close()
+}
+
+// writeEntry writes the header of e and its contents.
+func (z *ZipFS) writeEntry(e *archiveEntry, contents io.Reader) error {
+	fh := &zip.FileHeader{Name: e.name, Modified: e.mtime, Method: zip.Store}
+	fh.SetMode(e.mode)
+
+	switch {
+	case e.mode.IsDir():
+		fh.Name += "/"
+	case e.mode&fs.ModeSymlink != 0:
+		contents = strings.NewReader(e.linkname)
+	case e.mode.IsRegular():
+		fh.Method = zip.Deflate
+		if z.method != nil {
+			fh.Method = z.method(e.name, e.size)
+		}
+	default:
+		return &fs.PathError{Op: "write", Path: e.name, Err: errors.ErrUnsupported}
+	}
+
+	w, err := z.zw.CreateHeader(fh)
+	if err != nil {
+		return err
+	}
+	if contents != nil {
+		if _, err := io.Copy(w, contents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Link is not supported by zip, so that the hard links are copied as files.
+func (z *ZipFS) Link(oldname, newname string) error {
+	return &fs.PathError{Op: "link", Path: newname, Err: errors.ErrUnsupported}
+}
+
+// Lsetxattr is not supported by zip, so that extended attributes are ignored.
+func (z *ZipFS) Lsetxattr(name, attr string, value []byte) error {
+	return &fs.PathError{Op: "lsetxattr", Path: name, Err: errors.ErrUnsupported}
+}
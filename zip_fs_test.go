@@ -0,0 +1,104 @@
+//go:build !windows && !plan9 && !js
+
+package copy_go
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopy_zipFS(t *testing.T) {
+	src := t.TempDir()
+	mtime := time.Date(2020, 1, 2, 3, 4, 6, 0, time.UTC)
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	for name, perm := range map[string]os.FileMode{"a.txt": 0640, "b.jpg": 0644} {
+		if err := os.WriteFile(filepath.Join(src, "sub", name), []byte("hello"), perm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(src, "sub", name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(filepath.Join(src, "sub", "a.txt"), filepath.Join(src, "hardlink.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("sub/a.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	zfs := NewZipFS(&buf, ZipStoreExtensions(".jpg"))
+	err := Copy(src, "out", Options{
+		DestFS:            zfs,
+		PreserveTimes:     true,
+		PreserveHardlinks: true,
+		NumOfWorkers:      4,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := zfs.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	for name, want := range map[string]struct {
+		mode   fs.FileMode
+		method uint16
+	}{
+		"out/sub/a.txt":    {0640, zip.Deflate},
+		"out/sub/b.jpg":    {0644, zip.Store},
+		"out/hardlink.txt": {0640, zip.Deflate},
+	} {
+		f := files[name]
+		if f == nil {
+			t.Errorf("%s: not found in %v", name, files)
+			continue
+		}
+		if f.Mode() != want.mode || f.Method != want.method {
+			t.Errorf("%s: mode = %v, method = %d, want %v, %d", name, f.Mode(), f.Method, want.mode, want.method)
+		}
+		if !f.Modified.Equal(mtime) {
+			t.Errorf("%s: mtime = %v, want %v", name, f.Modified, mtime)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		if string(data) != "hello" {
+			t.Errorf("%s = %q, want %q", name, data, "hello")
+		}
+	}
+
+	if dir := files["out/sub/"]; dir == nil || dir.Mode() != fs.ModeDir|0750 {
+		t.Errorf("dir = %v", dir)
+	}
+	link := files["out/link"]
+	if link == nil || link.Mode()&fs.ModeSymlink == 0 {
+		t.Fatalf("symlink = %v", link)
+	}
+	rc, _ := link.Open()
+	target, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(target) != "sub/a.txt" {
+		t.Errorf("symlink target = %q, want %q", target, "sub/a.txt")
+	}
+}